@@ -0,0 +1,229 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tredoe/goutil/merrors"
+)
+
+// BuildOptions are the options to build a command to be tested.
+//
+// The command is built once per package and options into the temporary
+// directory of the test, which removes it at the end. While that binary is
+// available, the other tests link to it instead of building it again.
+type BuildOptions struct {
+	Tags    []string // build tags
+	Ldflags string   // arguments to pass on each "go tool link" invocation
+	Race    bool     // enable the race detector
+}
+
+// defaultBuild is used by the package-level functions.
+var defaultBuild = new(BuildOptions)
+
+// buildCache holds the binaries built, by package and options.
+var buildCache struct {
+	sync.Mutex
+	bins  map[string]*buildEntry
+	count int // number of builds
+}
+
+// buildEntry holds the binaries available of a command.
+type buildEntry struct {
+	sync.Mutex
+	paths map[string]bool
+}
+
+// RunCommands builds the command in the given directory, and runs every test
+// as a parallel subtest of t, named by the field Name of the test, or by its
+// index if it is empty.
+func (o *BuildOptions) RunCommands(t *testing.T, dir string, tests []CommandInfo) {
+	t.Helper()
+	cmdPath, cmdName := o.build(t, dir)
+
+	for i, tt := range tests {
+		i, tt := i, tt
+		name := tt.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var listErr merrors.ListError
+			tt.run(i, cmdPath, cmdName, &listErr)
+			if err := listErr.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// build links the command in dir, built with the options o, into the
+// temporary directory of t, building it if there is no binary available.
+// It returns the path of the binary, and the name to be used as program name.
+func (o *BuildOptions) build(t testing.TB, dir string) (path, name string) {
+	t.Helper()
+
+	path, name, release, err := o.cachedBuild(dir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Run before the temporary directory is removed.
+	t.Cleanup(release)
+	return path, name
+}
+
+// cachedBuild puts into outDir the command in dir built with the options o,
+// as a link to a binary available in the cache, or building it.
+// It returns the path of the binary, the name to be used as program name, and
+// the function to call before removing the binary.
+func (o *BuildOptions) cachedBuild(dir, outDir string) (path, name string, release func(), err error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", nil, err
+	}
+	key := strings.Join([]string{
+		absDir,
+		strings.Join(o.Tags, ","),
+		o.Ldflags,
+		strconv.FormatBool(o.Race),
+		strconv.FormatBool(coverEnabled()),
+	}, "\x00")
+
+	buildCache.Lock()
+	if buildCache.bins == nil {
+		buildCache.bins = make(map[string]*buildEntry)
+	}
+	e, found := buildCache.bins[key]
+	if !found {
+		e = &buildEntry{paths: make(map[string]bool)}
+		buildCache.bins[key] = e
+	}
+	buildCache.Unlock()
+
+	e.Lock()
+	defer e.Unlock()
+
+	for cached := range e.paths {
+		path = filepath.Join(outDir, filepath.Base(cached))
+		if err = linkFile(cached, path); err == nil {
+			name = "." + string(filepath.Separator) + filepath.Base(cached)
+			break
+		}
+		path = ""
+	}
+	if path == "" {
+		if path, name, err = buildCommand(dir, outDir, o); err != nil {
+			return "", "", nil, err
+		}
+		buildCache.Lock()
+		buildCache.count++
+		buildCache.Unlock()
+	}
+	e.paths[path] = true
+
+	release = func() {
+		e.Lock()
+		delete(e.paths, path)
+		e.Unlock()
+	}
+	return path, name, release, nil
+}
+
+// buildCommand builds the command in the given directory into outDir, with the
+// options o.
+// It returns the path of the executable, and its relative path like
+// "./_cmd_<dir>", to be used as program name so that the outputs do not
+// depend on the directory where it was built.
+func buildCommand(dir, outDir string, o *BuildOptions) (path, name string, err error) {
+	cmdFile := "_cmd_"
+
+	if dir == "" || dir == "." {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", "", err
+		}
+		cmdFile += filepath.Base(wd)
+
+	} else {
+		if dir[0] != '.' && !filepath.IsAbs(dir) {
+			dir = "." + string(filepath.Separator) + dir
+		}
+		cmdFile += filepath.Base(dir)
+	}
+
+	if runtime.GOOS == "windows" {
+		cmdFile += ".exe"
+	}
+	path = filepath.Join(outDir, cmdFile)
+
+	args := []string{"build", "-o", path}
+	if len(o.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(o.Tags, ","))
+	}
+	if o.Ldflags != "" {
+		args = append(args, "-ldflags", o.Ldflags)
+	}
+	if o.Race {
+		args = append(args, "-race")
+	}
+	if coverEnabled() {
+		args = append(args, "-cover", "-covermode="+testing.CoverMode())
+	}
+	args = append(args, dir)
+
+	if out, err := exec.Command("go", args...).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("%s\n%s", out, err)
+	}
+	return path, "." + string(filepath.Separator) + cmdFile, nil
+}
+
+// linkFile creates newname as a hard link to oldname, or as a copy if the
+// link can not be created.
+func linkFile(oldname, newname string) error {
+	if err := os.Link(oldname, newname); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(oldname)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(newname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(newname)
+		return err
+	}
+	return dst.Close()
+}
+
+// * * *
+
+// RunCommands builds the command in the given directory, and runs every test
+// as a parallel subtest of t. See (*BuildOptions).RunCommands.
+func RunCommands(t *testing.T, dir string, tests []CommandInfo) {
+	t.Helper()
+	defaultBuild.RunCommands(t, dir, tests)
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package cmdtest gives access to the command tests of package cmdutil from
+// the test binaries, registering the flag -update to rewrite the golden files:
+//
+//	go test -update
+//
+// It is meant to be imported only by tests. A test binary which imports it
+// must not define its own flag -update.
+package cmdtest
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/tredoe/goutil/cmdutil"
+)
+
+func init() {
+	flag.BoolVar(&cmdutil.UpdateGolden, "update", false, "rewrite the golden files of the command tests")
+}
+
+// Types of package cmdutil.
+type (
+	CommandInfo  = cmdutil.CommandInfo
+	BuildOptions = cmdutil.BuildOptions
+	Matcher      = cmdutil.Matcher
+	Normalize    = cmdutil.Normalize
+)
+
+// Normalizations
+const (
+	NormTrailingSpace = cmdutil.NormTrailingSpace
+	NormTempPaths     = cmdutil.NormTempPaths
+)
+
+// DefaultTimeout is the maximum time to run a command if CommandInfo.Timeout
+// is not set.
+const DefaultTimeout = cmdutil.DefaultTimeout
+
+// TestCommand is like cmdutil.TestCommand.
+func TestCommand(dir string, tests []CommandInfo) error {
+	return cmdutil.TestCommand(dir, tests)
+}
+
+// RunCommands is like cmdutil.RunCommands.
+func RunCommands(t *testing.T, dir string, tests []CommandInfo) {
+	t.Helper()
+	cmdutil.RunCommands(t, dir, tests)
+}
+
+// RunScripts is like cmdutil.RunScripts.
+func RunScripts(t *testing.T, dir string) {
+	t.Helper()
+	cmdutil.RunScripts(t, dir)
+}
+
+// CoverMain is like cmdutil.CoverMain.
+func CoverMain(m *testing.M) int { return cmdutil.CoverMain(m) }
+
+// SplitArgs is like cmdutil.SplitArgs.
+func SplitArgs(s string) ([]string, error) { return cmdutil.SplitArgs(s) }
+
+// MatchRegexp is like cmdutil.MatchRegexp.
+func MatchRegexp(pattern string) Matcher { return cmdutil.MatchRegexp(pattern) }
+
+// MatchContains is like cmdutil.MatchContains.
+func MatchContains(substrs ...string) Matcher { return cmdutil.MatchContains(substrs...) }
+
+// MatchLines is like cmdutil.MatchLines.
+func MatchLines(text string) Matcher { return cmdutil.MatchLines(text) }
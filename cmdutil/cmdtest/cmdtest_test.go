@@ -0,0 +1,29 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdtest
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/tredoe/goutil/cmdutil"
+)
+
+func TestUpdateFlag(t *testing.T) {
+	f := flag.Lookup("update")
+	if f == nil {
+		t.Fatal("flag -update not registered")
+	}
+	defer f.Value.Set("false")
+
+	if err := f.Value.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	if !cmdutil.UpdateGolden {
+		t.Error("expected cmdutil.UpdateGolden to be set by the flag")
+	}
+}
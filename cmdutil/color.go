@@ -0,0 +1,120 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tredoe/goutil/internal/term"
+)
+
+// ColorMode indicates when the output is colored.
+// It can be used as a flag.Value, like "--color=auto|always|never".
+type ColorMode int
+
+// Modes of color.
+const (
+	ColorAuto   ColorMode = iota // only when the writer is a terminal; by default
+	ColorAlways                  // always
+	ColorNever                   // never
+)
+
+var colorModeNames = [...]string{
+	ColorAuto:   "auto",
+	ColorAlways: "always",
+	ColorNever:  "never",
+}
+
+// String returns the name of the mode.
+func (m ColorMode) String() string {
+	if m >= 0 && int(m) < len(colorModeNames) {
+		return colorModeNames[m]
+	}
+	return fmt.Sprintf("ColorMode(%d)", int(m))
+}
+
+// Set sets the mode from its name.
+func (m *ColorMode) Set(s string) error {
+	for i, v := range colorModeNames {
+		if v == s {
+			*m = ColorMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid color mode %q: must be auto, always or never", s)
+}
+
+// Enabled reports whether the output to w has to be colored.
+//
+// In mode ColorAuto, the output is not colored if the environment variable
+// NO_COLOR is set and not empty; else it is colored if CLICOLOR_FORCE is set
+// and not "0", or if w is a terminal.
+func (m ColorMode) Enabled(w io.Writer) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	return term.ColorEnabled(w)
+}
+
+// Style represents a text attribute, an ANSI SGR code.
+type Style string
+
+// Styles
+const (
+	Bold      Style = "1"
+	Dim       Style = "2"
+	Italic    Style = "3"
+	Underline Style = "4"
+
+	Red     Style = "31"
+	Green   Style = "32"
+	Yellow  Style = "33"
+	Blue    Style = "34"
+	Magenta Style = "35"
+	Cyan    Style = "36"
+)
+
+// Apply returns the text with the given styles, whatever the color mode.
+func Apply(text string, styles ...Style) string {
+	if len(styles) == 0 || text == "" {
+		return text
+	}
+	codes := make([]string, len(styles))
+	for i, v := range styles {
+		codes[i] = string(v)
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m" + text + "\x1b[0m"
+}
+
+// levelStyles are the styles of the messages by level.
+var levelStyles = map[Level]Style{
+	LevelDebug: Dim,
+	LevelInfo:  Green,
+	LevelWarn:  Yellow,
+	LevelError: Red,
+}
+
+// Paint returns the text with the given styles if the output of the Printer
+// to Stdout has to be colored; else it returns the text as is.
+func (p *Printer) Paint(text string, styles ...Style) string {
+	w, _ := p.writer(LevelInfo)
+	if !p.Color.Enabled(w) {
+		return text
+	}
+	return Apply(text, styles...)
+}
+
+// Paint returns the text with the given styles, through the default Printer.
+func Paint(text string, styles ...Style) string { return std.Paint(text, styles...) }
+
+// SetColor sets the color mode of the default Printer.
+func SetColor(mode ColorMode) { std.Color = mode }
@@ -0,0 +1,237 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// coverDir is the directory where the coverage data of all commands run by
+// the test binary is gathered.
+var coverDir struct {
+	sync.Mutex
+	path   string
+	active bool // CoverMain is running
+}
+
+// coverEnabled reports whether the commands have to be built with coverage
+// instrumentation.
+func coverEnabled() bool {
+	coverDir.Lock()
+	defer coverDir.Unlock()
+	return coverDir.active && testing.CoverMode() != ""
+}
+
+// CoverMain runs the tests through m.Run, like in a function TestMain, and
+// adds to the coverage profile the coverage data of the commands run by
+// TestCommand and RunScripts. It returns the exit code to pass to os.Exit:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(cmdutil.CoverMain(m))
+//	}
+//
+// When the coverage is enabled, like in "go test -cover", and the tests are
+// run by CoverMain, the commands are built with coverage instrumentation, and
+// every run writes its data into its own directory set in GOCOVERDIR.
+// The data is merged at the end into the file given by -coverprofile, and
+// only the files which are already in that profile are updated, so the
+// coverage of the packages tested is the one exercised by both the tests and
+// the commands. The percentage printed by "go test" is not updated; use
+// "go tool cover -func" on the profile.
+func CoverMain(m *testing.M) int {
+	coverDir.Lock()
+	coverDir.active = true
+	coverDir.Unlock()
+
+	code := m.Run()
+
+	coverDir.Lock()
+	dir := coverDir.path
+	coverDir.active = false
+	coverDir.Unlock()
+	if dir == "" {
+		return code
+	}
+	defer os.RemoveAll(dir)
+
+	if err := mergeProfile(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "cmdutil: coverage of commands: %s\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// setCoverDir sets the environment variable GOCOVERDIR of cmd to a new
+// temporary directory, if the coverage is enabled by CoverMain.
+// It returns the directory, or an empty string if the coverage is not enabled.
+func setCoverDir(cmd *exec.Cmd) (string, error) {
+	if !coverEnabled() {
+		return "", nil
+	}
+	dir, err := os.MkdirTemp("", "cmdutil-cover-")
+	if err != nil {
+		return "", err
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GOCOVERDIR="+dir)
+	return dir, nil
+}
+
+// mergeCoverage moves the coverage data written by a command into dir, to the
+// directory where the data of all commands is gathered, and removes dir.
+func mergeCoverage(dir string) error {
+	defer os.RemoveAll(dir)
+
+	coverDir.Lock()
+	defer coverDir.Unlock()
+
+	if coverDir.path == "" {
+		path, err := os.MkdirTemp("", "cmdutil-coverdata-")
+		if err != nil {
+			return err
+		}
+		coverDir.path = path
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := filepath.Join(coverDir.path, f.Name())
+
+		// The meta-data files are named by their hash, so they are equal for
+		// all runs of the same binary; the counter data files are unique.
+		if _, err := os.Stat(name); err == nil {
+			continue
+		}
+		if err = os.Rename(filepath.Join(dir, f.Name()), name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeProfile merges the coverage data in dir into the coverage profile
+// written by the test binary, if any.
+func mergeProfile(dir string) error {
+	f := flag.Lookup("test.coverprofile")
+	if f == nil || f.Value.String() == "" {
+		return nil
+	}
+	profile := f.Value.String()
+	if out := flag.Lookup("test.outputdir"); out != nil && out.Value.String() != "" &&
+		!filepath.IsAbs(profile) {
+		profile = filepath.Join(out.Value.String(), profile)
+	}
+
+	text := filepath.Join(dir, "profile.txt")
+	out, err := exec.Command("go", "tool", "covdata", "textfmt", "-i="+dir, "-o="+text).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s\n%s", out, err)
+	}
+
+	dst, err := os.ReadFile(profile)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(text)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeProfiles(dst, src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(profile, merged, 0644)
+}
+
+// mergeProfiles adds to the coverage profile dst the counts of the blocks in
+// the profile src whose files are in dst. Both profiles are in the text format
+// written by "go test -coverprofile".
+func mergeProfiles(dst, src []byte) ([]byte, error) {
+	type block struct {
+		key   string // file and position, and number of statements
+		count int
+	}
+	var mode string
+	var blocks []block
+	index := make(map[string]int) // by key
+	files := make(map[string]bool)
+
+	for i, data := range [][]byte{dst, src} {
+		s := bufio.NewScanner(bytes.NewReader(data))
+		for s.Scan() {
+			line := s.Text()
+			if strings.HasPrefix(line, "mode: ") {
+				if i == 0 {
+					mode = line
+				}
+				continue
+			}
+			if line == "" {
+				continue
+			}
+
+			sep := strings.LastIndexByte(line, ' ')
+			colon := strings.LastIndexByte(line, ':')
+			if sep == -1 || colon == -1 {
+				return nil, fmt.Errorf("invalid line in coverage profile: %q", line)
+			}
+			count, err := strconv.Atoi(line[sep+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid line in coverage profile: %q", line)
+			}
+			key, file := line[:sep], line[:colon]
+
+			if i == 0 {
+				files[file] = true
+			} else if !files[file] {
+				continue
+			}
+
+			j, found := index[key]
+			if !found {
+				index[key] = len(blocks)
+				blocks = append(blocks, block{key, count})
+				continue
+			}
+			if mode == "mode: set" {
+				if count != 0 {
+					blocks[j].count = 1
+				}
+			} else {
+				blocks[j].count += count
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(mode + "\n")
+	for _, v := range blocks {
+		fmt.Fprintf(&b, "%s %d\n", v.key, v.count)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around the changes.
+const diffContext = 3
+
+// diff returns the differences between the texts want and got, in unified
+// format. It returns an empty string if they are equal.
+func diff(nameWant, nameGot, want, got string) string {
+	if want == got {
+		return ""
+	}
+	a := splitLines(want)
+	b := splitLines(got)
+
+	// Longest common subsequence, from the end.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte // ' ', '-', '+'
+		line string
+		i, j int // line numbers in a and b
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		default:
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameWant, nameGot)
+
+	var changes []int // index of the edits which are not equal
+	for k, e := range edits {
+		if e.op != ' ' {
+			changes = append(changes, k)
+		}
+	}
+
+	for c := 0; c < len(changes); {
+		// Join the changes separated by up to 2*diffContext unchanged lines.
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext+1 {
+			last++
+		}
+
+		start := changes[c] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[last] + 1 + diffContext
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		var nA, nB int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				nA++
+			}
+			if e.op != '-' {
+				nB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n",
+			edits[start].i+1, nA, edits[start].j+1, nB)
+		for _, e := range edits[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", e.op, e.line)
+		}
+		c = last + 1
+	}
+	return out.String()
+}
+
+// splitLines splits s in lines; a final line without newline is marked.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, v := range lines {
+		if strings.HasSuffix(v, "\n") {
+			lines[i] = v[:len(v)-1]
+		} else {
+			lines[i] = v + "\n\\ No newline at end of file"
+		}
+	}
+	return lines
+}
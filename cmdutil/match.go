@@ -0,0 +1,223 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SplitArgs splits the string s in arguments like a POSIX shell does, but
+// without any expansion: the arguments are separated by spaces; the text
+// between single quotes is taken literally; between double quotes, the
+// backslash only escapes the characters '"', '\', '$' and '`'; and outside of
+// quotes, it escapes any character.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false // to handle empty arguments, like ''
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+			continue
+
+		case c == '\\':
+			if i++; i == len(s) {
+				return nil, errors.New("backslash at end of arguments")
+			}
+			arg.WriteByte(s[i])
+
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end == -1 {
+				return nil, errors.New("unterminated single quote")
+			}
+			arg.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+
+		case c == '"':
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) != -1 {
+					i++
+				}
+				arg.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+
+		default:
+			arg.WriteByte(c)
+		}
+		inArg = true
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// * * *
+
+// A Matcher checks whether an output is the expected one.
+type Matcher interface {
+	// Match returns an error describing why the output does not match.
+	Match(output string) error
+}
+
+type matcherFunc func(output string) error
+
+func (f matcherFunc) Match(output string) error { return f(output) }
+
+// MatchRegexp returns a Matcher which reports whether the output matches the
+// regular expression pattern. It panics if the pattern is not valid.
+func MatchRegexp(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+
+	return matcherFunc(func(output string) error {
+		if !re.MatchString(output) {
+			return fmt.Errorf("does not match regexp %q", pattern)
+		}
+		return nil
+	})
+}
+
+// MatchContains returns a Matcher which reports whether the output contains
+// all the given substrings.
+func MatchContains(substrs ...string) Matcher {
+	return matcherFunc(func(output string) error {
+		var missing []string
+		for _, v := range substrs {
+			if !strings.Contains(output, v) {
+				missing = append(missing, fmt.Sprintf("%q", v))
+			}
+		}
+		if len(missing) != 0 {
+			return fmt.Errorf("does not contain %s", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// MatchLines returns a Matcher which reports whether the output has the same
+// lines than text, in whatever order.
+func MatchLines(text string) Matcher {
+	want := sortedLines(text)
+
+	return matcherFunc(func(output string) error {
+		got := sortedLines(output)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			return fmt.Errorf("has not the lines expected, in whatever order\n%s",
+				diff("want (sorted)", "got (sorted)",
+					strings.Join(want, "\n")+"\n", strings.Join(got, "\n")+"\n"))
+		}
+		return nil
+	})
+}
+
+func sortedLines(s string) []string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+// * * *
+
+// Normalize indicates the changes applied to the outputs of a command, before
+// of being compared with the ones expected.
+type Normalize int
+
+// Normalizations
+const (
+	// NormTrailingSpace removes the white space at the end of every line.
+	NormTrailingSpace Normalize = 1 << iota
+
+	// NormTempPaths replaces the temporary working directory of the command by
+	// "$WORK", and the temporary directory of the system by "$TMPDIR".
+	// Only the whole paths, or their starts before a path separator, are
+	// replaced.
+	NormTempPaths
+)
+
+// apply returns the output s normalized; work is the temporary working
+// directory of the command, if any.
+func (n Normalize) apply(s, work string) string {
+	if n&NormTempPaths != 0 {
+		var pairs []string
+		if work != "" {
+			pairs = append(pairs, work, "$WORK")
+			if real, err := filepath.EvalSymlinks(work); err == nil && real != work {
+				pairs = append(pairs, real, "$WORK")
+			}
+		}
+		tmp := filepath.Clean(os.TempDir())
+		pairs = append(pairs, tmp, "$TMPDIR")
+		if real, err := filepath.EvalSymlinks(tmp); err == nil && real != tmp {
+			pairs = append(pairs, real, "$TMPDIR")
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			s = replacePath(s, pairs[i], pairs[i+1])
+		}
+	}
+
+	if n&NormTrailingSpace != 0 {
+		lines := strings.Split(s, "\n")
+		for i, v := range lines {
+			lines[i] = strings.TrimRight(v, " \t\r")
+		}
+		s = strings.Join(lines, "\n")
+	}
+	return s
+}
+
+// replacePath replaces the path old by new in s, where it is a whole path or
+// the start of one: not preceded nor followed by other characters of a file
+// name, but by the end of a token or, after it, by a path separator.
+func replacePath(s, old, new string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, old)
+		if i == -1 {
+			break
+		}
+		end := i + len(old)
+		if (i == 0 || isPathBoundary(s[i-1])) && (end == len(s) || isPathBoundary(s[end])) {
+			b.WriteString(s[:i])
+			b.WriteString(new)
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// isPathBoundary reports whether c ends a path component, like a path
+// separator, a space or a quote.
+func isPathBoundary(c byte) bool {
+	return c == '/' || c == filepath.Separator || strings.IndexByte(" \t\r\n'\"`:;,()[]<>=", c) != -1
+}
@@ -8,7 +8,11 @@ package cmdutil
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -27,77 +31,303 @@ func SetPrefix(stdout, stderr string) {
 	}
 }
 
-// Error is equivalent to Print() but in Stderr.
-func Error(v ...interface{}) {
-	if StderrPrefix != "" {
-		fmt.Fprint(os.Stderr, StderrPrefix)
+// Level represents the level of importance of a message.
+type Level int
+
+// Levels of messages.
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo        // by default
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if s, ok := levelNames[l]; ok {
+		return s
 	}
-	fmt.Fprint(os.Stderr, v...)
+	return fmt.Sprintf("level(%d)", int(l))
 }
 
-// Errorf is equivalent to Printf() but in Stderr.
-func Errorf(format string, v ...interface{}) {
-	if StderrPrefix != "" {
-		fmt.Fprint(os.Stderr, StderrPrefix)
+// Set sets the level from its name, so a Level can be used as a flag.Value.
+func (l *Level) Set(s string) error {
+	for k, v := range levelNames {
+		if v == s {
+			*l = k
+			return nil
+		}
 	}
-	fmt.Fprintf(os.Stderr, format, v...)
+	return fmt.Errorf("unknown level %q", s)
 }
 
-// Errorln is equivalent to Println() but in Stderr.
-func Errorln(v ...interface{}) {
-	if StderrPrefix != "" {
-		fmt.Fprint(os.Stderr, StderrPrefix)
+// * * *
+
+// A Printer writes messages to the standard output and error, with a prefix
+// and the level of importance. The messages of levels debug and info are
+// written to Stdout, and the rest to Stderr.
+//
+// The zero value is ready to use: it writes to os.Stdout and os.Stderr, like
+// any nil writer, and without prefixes. A Printer can be safely used from
+// multiple goroutines.
+type Printer struct {
+	Stdout       io.Writer
+	Stderr       io.Writer
+	StdoutPrefix string
+	StderrPrefix string
+
+	// Level is the minimum level of the messages to print.
+	Level Level
+
+	// TimeFormat is the layout of the timestamp printed before every message,
+	// as in time.Format; there is no timestamp if it is empty.
+	TimeFormat string
+
+	// Color indicates when the prefix and the message are colored according
+	// to the level: green for info, yellow for warn, and red for error.
+	Color ColorMode
+
+	mu     *sync.Mutex // shared by the copies made by With; set by lock
+	fields []interface{}
+	global bool // use the global prefixes and standard files
+}
+
+// NewPrinter returns a Printer which writes to stdout and stderr, with the
+// prefixes by default.
+func NewPrinter(stdout, stderr io.Writer) *Printer {
+	return &Printer{
+		Stdout:       stdout,
+		Stderr:       stderr,
+		StdoutPrefix: " * ",
+		StderrPrefix: " [!] ",
 	}
-	fmt.Fprintln(os.Stderr, v...)
 }
 
-// Fatal is equivalent to Print() followed by a call to os.Exit(1).
-func Fatal(v ...interface{}) {
-	if StderrPrefix != "" {
-		fmt.Fprint(os.Stderr, StderrPrefix)
+// std is the Printer used by the package-level functions.
+// It uses the variables StdoutPrefix and StderrPrefix, and the files
+// os.Stdout and os.Stderr at the time of printing.
+var std = &Printer{global: true}
+
+// initMu guards the initialization of the mutex of the printers.
+var initMu sync.Mutex
+
+// lock locks the mutex of the Printer, creating it if it is not set.
+func (p *Printer) lock() *sync.Mutex {
+	initMu.Lock()
+	if p.mu == nil {
+		p.mu = new(sync.Mutex)
 	}
-	fmt.Fprint(os.Stderr, v...)
-	os.Exit(1)
+	mu := p.mu
+	initMu.Unlock()
+
+	mu.Lock()
+	return mu
 }
 
-// Fatalf is equivalent to Printf() followed by a call to os.Exit(1).
-func Fatalf(format string, v ...interface{}) {
-	if StderrPrefix != "" {
-		fmt.Fprint(os.Stderr, StderrPrefix)
+// Default returns the Printer used by the package-level functions.
+func Default() *Printer { return std }
+
+// SetVerbosity sets the level according to the flags to be verbose and to
+// be quiet: LevelDebug, LevelWarn, or LevelInfo if none or both are set.
+func (p *Printer) SetVerbosity(verbose, quiet bool) {
+	defer p.lock().Unlock()
+
+	switch {
+	case verbose && !quiet:
+		p.Level = LevelDebug
+	case quiet && !verbose:
+		p.Level = LevelWarn
+	default:
+		p.Level = LevelInfo
 	}
-	fmt.Fprintf(os.Stderr, format, v...)
-	os.Exit(1)
 }
 
-// Fatalln is equivalent to Println() followed by a call to os.Exit(1).
-func Fatalln(v ...interface{}) {
-	if StderrPrefix != "" {
-		fmt.Fprint(os.Stderr, StderrPrefix)
+// With returns a copy of the Printer which adds the given key-value pairs to
+// every message printed by methods Debug, Info, Warn and Log.
+func (p *Printer) With(keyvals ...interface{}) *Printer {
+	mu := p.lock()
+	p2 := *p
+	mu.Unlock()
+
+	p2.fields = append(append([]interface{}{}, p.fields...), keyvals...)
+	return &p2
+}
+
+// Enabled reports whether the messages of the given level are printed.
+func (p *Printer) Enabled(level Level) bool {
+	defer p.lock().Unlock()
+	return level >= p.Level
+}
+
+func (p *Printer) writer(level Level) (w io.Writer, prefix string) {
+	if level >= LevelWarn {
+		if p.global {
+			return os.Stderr, StderrPrefix
+		}
+		if p.Stderr == nil {
+			return os.Stderr, p.StderrPrefix
+		}
+		return p.Stderr, p.StderrPrefix
 	}
-	fmt.Fprintln(os.Stderr, v...)
-	os.Exit(1)
+	if p.global {
+		return os.Stdout, StdoutPrefix
+	}
+	if p.Stdout == nil {
+		return os.Stdout, p.StdoutPrefix
+	}
+	return p.Stdout, p.StdoutPrefix
 }
 
-// Print is equivalent to Print().
-func Print(v ...interface{}) {
-	if StdoutPrefix != "" {
-		fmt.Print(StdoutPrefix)
+// output writes the message at the given level, with prefix and timestamp.
+func (p *Printer) output(level Level, msg string) {
+	if !p.Enabled(level) {
+		return
+	}
+	w, prefix := p.writer(level)
+
+	if p.TimeFormat != "" {
+		msg = time.Now().Format(p.TimeFormat) + " " + msg
 	}
-	fmt.Print(v...)
+	if p.Color.Enabled(w) {
+		style := levelStyles[level]
+		// The newline is kept out of the colored text.
+		body := strings.TrimRight(msg, "\n")
+		prefix = Apply(prefix, Bold, style)
+		msg = Apply(body, style) + msg[len(body):]
+	}
+	text := prefix + msg
+
+	defer p.lock().Unlock()
+	io.WriteString(w, text)
 }
 
-// Printf is equivalent to Printf().
-func Printf(format string, v ...interface{}) {
-	if StdoutPrefix != "" {
-		fmt.Print(StdoutPrefix)
+// Log prints msg at the given level, followed by the key-value pairs in
+// format "key=value", and a newline.
+func (p *Printer) Log(level Level, msg string, keyvals ...interface{}) {
+	if !p.Enabled(level) {
+		return
 	}
-	fmt.Printf(format, v...)
+	var b strings.Builder
+	b.WriteString(msg)
+	writeFields(&b, p.fields)
+	writeFields(&b, keyvals)
+	b.WriteByte('\n')
+
+	p.output(level, b.String())
 }
 
-// Println is equivalent to Println().
-func Println(v ...interface{}) {
-	if StdoutPrefix != "" {
-		fmt.Print(StdoutPrefix)
+// Debug prints msg at LevelDebug; see Log.
+func (p *Printer) Debug(msg string, keyvals ...interface{}) {
+	p.Log(LevelDebug, msg, keyvals...)
+}
+
+// Info prints msg at LevelInfo; see Log.
+func (p *Printer) Info(msg string, keyvals ...interface{}) {
+	p.Log(LevelInfo, msg, keyvals...)
+}
+
+// Warn prints msg at LevelWarn; see Log.
+func (p *Printer) Warn(msg string, keyvals ...interface{}) {
+	p.Log(LevelWarn, msg, keyvals...)
+}
+
+// writeFields writes the key-value pairs to b.
+// A key without value gets the value "(MISSING)".
+func writeFields(b *strings.Builder, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		val := fmt.Sprint(v)
+		if val == "" || strings.ContainsAny(val, " \t\n\"=") {
+			val = fmt.Sprintf("%q", val)
+		}
+		fmt.Fprintf(b, " %v=%s", keyvals[i], val)
 	}
-	fmt.Println(v...)
 }
+
+// Error is equivalent to Print() but in Stderr.
+func (p *Printer) Error(v ...interface{}) { p.output(LevelError, fmt.Sprint(v...)) }
+
+// Errorf is equivalent to Printf() but in Stderr.
+func (p *Printer) Errorf(format string, v ...interface{}) {
+	p.output(LevelError, fmt.Sprintf(format, v...))
+}
+
+// Errorln is equivalent to Println() but in Stderr.
+func (p *Printer) Errorln(v ...interface{}) { p.output(LevelError, fmt.Sprintln(v...)) }
+
+// Fatal is equivalent to Error() followed by a call to os.Exit(1).
+func (p *Printer) Fatal(v ...interface{}) {
+	p.Error(v...)
+	os.Exit(1)
+}
+
+// Fatalf is equivalent to Errorf() followed by a call to os.Exit(1).
+func (p *Printer) Fatalf(format string, v ...interface{}) {
+	p.Errorf(format, v...)
+	os.Exit(1)
+}
+
+// Fatalln is equivalent to Errorln() followed by a call to os.Exit(1).
+func (p *Printer) Fatalln(v ...interface{}) {
+	p.Errorln(v...)
+	os.Exit(1)
+}
+
+// Print is equivalent to fmt.Print() in Stdout, at LevelInfo.
+func (p *Printer) Print(v ...interface{}) { p.output(LevelInfo, fmt.Sprint(v...)) }
+
+// Printf is equivalent to fmt.Printf() in Stdout, at LevelInfo.
+func (p *Printer) Printf(format string, v ...interface{}) {
+	p.output(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// Println is equivalent to fmt.Println() in Stdout, at LevelInfo.
+func (p *Printer) Println(v ...interface{}) { p.output(LevelInfo, fmt.Sprintln(v...)) }
+
+// * * *
+
+// Error is equivalent to Print() but in Stderr.
+func Error(v ...interface{}) { std.Error(v...) }
+
+// Errorf is equivalent to Printf() but in Stderr.
+func Errorf(format string, v ...interface{}) { std.Errorf(format, v...) }
+
+// Errorln is equivalent to Println() but in Stderr.
+func Errorln(v ...interface{}) { std.Errorln(v...) }
+
+// Fatal is equivalent to Print() followed by a call to os.Exit(1).
+func Fatal(v ...interface{}) { std.Fatal(v...) }
+
+// Fatalf is equivalent to Printf() followed by a call to os.Exit(1).
+func Fatalf(format string, v ...interface{}) { std.Fatalf(format, v...) }
+
+// Fatalln is equivalent to Println() followed by a call to os.Exit(1).
+func Fatalln(v ...interface{}) { std.Fatalln(v...) }
+
+// Print is equivalent to Print().
+func Print(v ...interface{}) { std.Print(v...) }
+
+// Printf is equivalent to Printf().
+func Printf(format string, v ...interface{}) { std.Printf(format, v...) }
+
+// Println is equivalent to Println().
+func Println(v ...interface{}) { std.Println(v...) }
+
+// Debug prints msg at LevelDebug, through the default Printer.
+func Debug(msg string, keyvals ...interface{}) { std.Debug(msg, keyvals...) }
+
+// Info prints msg at LevelInfo, through the default Printer.
+func Info(msg string, keyvals ...interface{}) { std.Info(msg, keyvals...) }
+
+// Warn prints msg at LevelWarn, through the default Printer.
+func Warn(msg string, keyvals ...interface{}) { std.Warn(msg, keyvals...) }
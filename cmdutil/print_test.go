@@ -0,0 +1,100 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	p := NewPrinter(&stdout, &stderr)
+	p.Color = ColorNever
+
+	p.Debug("hidden")
+	p.Info("starting", "port", 80)
+	p.With("service", "web").Warn("slow response", "took", "2 s")
+	p.Errorf("failed: %s\n", "timeout")
+
+	p.SetVerbosity(false, true)
+	p.Println("hidden")
+	p.SetVerbosity(true, false)
+	p.Debug("visible", "key")
+
+	wantOut := " * starting port=80\n * visible key=(MISSING)\n"
+	if got := stdout.String(); got != wantOut {
+		t.Errorf("stdout: got %q, want %q", got, wantOut)
+	}
+	wantErr := " [!] slow response service=web took=\"2 s\"\n [!] failed: timeout\n"
+	if got := stderr.String(); got != wantErr {
+		t.Errorf("stderr: got %q, want %q", got, wantErr)
+	}
+
+	var l Level
+	if err := l.Set("warn"); err != nil || l != LevelWarn {
+		t.Errorf("Level.Set: got %v, %v", l, err)
+	}
+}
+
+func TestPrinterZero(t *testing.T) {
+	var p Printer
+	p.SetVerbosity(false, true)
+	p.Println("hidden")
+
+	var stdout bytes.Buffer
+	p2 := &Printer{Stdout: &stdout}
+	p3 := p2.With("id", 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p2.SetVerbosity(false, false)
+			p2.Print("a")
+			p3.Info("b")
+		}()
+	}
+	wg.Wait()
+
+	if got := stdout.String(); len(got) != 4*len("ab id=1\n") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestColor(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	p := NewPrinter(&stdout, &stderr)
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+	p.Warn("auto")
+
+	t.Setenv("CLICOLOR_FORCE", "1")
+	p.Warn("forced")
+
+	p.Color = ColorNever
+	p.Warn("never")
+
+	want := " [!] auto\n" +
+		"\x1b[1;33m [!] \x1b[0m\x1b[33mforced\x1b[0m\n" +
+		" [!] never\n"
+	if got := stderr.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var mode ColorMode
+	if err := mode.Set("always"); err != nil || mode != ColorAlways {
+		t.Errorf("ColorMode.Set: got %v, %v", mode, err)
+	}
+	p.Color = mode
+	if got := p.Paint("ok", Bold, Green); got != "\x1b[1;32mok\x1b[0m" {
+		t.Errorf("Paint: got %q", got)
+	}
+}
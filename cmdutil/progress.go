@@ -0,0 +1,272 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tredoe/goutil/internal/term"
+)
+
+// Intervals to update the progress.
+const (
+	termInterval  = 100 * time.Millisecond
+	plainInterval = 5 * time.Second
+)
+
+const barWidth = 30
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// Progress reports the progress of several tasks, one line per task.
+//
+// When the writer is a terminal, the lines are redrawn in place; otherwise,
+// the state of the tasks which have changed is printed periodically as plain
+// lines, and at finishing every task.
+//
+// Its methods can be safely called from multiple goroutines.
+type Progress struct {
+	w        io.Writer
+	tty      bool
+	interval time.Duration
+
+	mu      sync.Mutex
+	bars    []*Bar
+	lines   int // number of lines drawn at the terminal
+	frame   int // frame of the spinners
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+	single  bool // stop when its only bar is done
+}
+
+// NewProgress returns a Progress which writes to w.
+func NewProgress(w io.Writer) *Progress {
+	p := &Progress{
+		w:        w,
+		tty:      term.IsTerminal(w),
+		interval: plainInterval,
+	}
+	if p.tty {
+		p.interval = termInterval
+	}
+	return p
+}
+
+// AddBar adds a task whose total amount of work is known.
+func (p *Progress) AddBar(name string, total int64) *Bar {
+	return p.add(name, total)
+}
+
+// AddSpinner adds a task whose total amount of work is unknown.
+func (p *Progress) AddSpinner(name string) *Bar {
+	return p.add(name, -1)
+}
+
+func (p *Progress) add(name string, total int64) *Bar {
+	b := &Bar{p: p, name: name, total: total, start: time.Now(), printed: -1}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.bars = append(p.bars, b)
+	if p.stop == nil && !p.stopped {
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go p.loop()
+	}
+	return b
+}
+
+func (p *Progress) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			p.render(false)
+			p.mu.Unlock()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Stop stops updating the progress, after printing the last state of the tasks.
+func (p *Progress) Stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.stopped = true
+	stop, done := p.stop, p.done
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	p.mu.Lock()
+	p.render(true)
+	p.mu.Unlock()
+}
+
+// render writes the state of the tasks; it must be called with the lock held.
+// At the last rendering, the tasks not finished are printed as they are.
+func (p *Progress) render(last bool) {
+	now := time.Now()
+	p.frame++
+
+	if p.tty {
+		var b strings.Builder
+		if p.lines > 0 {
+			fmt.Fprintf(&b, "\x1b[%dA", p.lines) // cursor up
+		}
+		for _, bar := range p.bars {
+			b.WriteString("\x1b[2K") // clear line
+			b.WriteString(bar.line(now, p.frame))
+			b.WriteByte('\n')
+		}
+		p.lines = len(p.bars)
+		io.WriteString(p.w, b.String())
+		return
+	}
+
+	for _, bar := range p.bars {
+		current := atomic.LoadInt64(&bar.current)
+		finished := bar.isDone()
+
+		if bar.printedDone || (current == bar.printed && !finished && !last) {
+			continue
+		}
+		if finished {
+			bar.printedDone = true
+		}
+		bar.printed = current
+		fmt.Fprintln(p.w, bar.line(now, -1))
+	}
+}
+
+// * * *
+
+// A Bar represents a task in a Progress; a bar if the total amount of work
+// is known, or a spinner else.
+type Bar struct {
+	p       *Progress
+	name    string
+	total   int64 // -1 for a spinner
+	current int64 // accessed atomically
+	start   time.Time
+	end     atomic.Value // time.Time when it is done
+
+	printed     int64 // last value printed as plain line
+	printedDone bool
+}
+
+// NewBar returns a Bar, with its own Progress which writes to w.
+// The progress is stopped when the bar is done.
+func NewBar(w io.Writer, name string, total int64) *Bar {
+	p := NewProgress(w)
+	p.single = true
+	return p.AddBar(name, total)
+}
+
+// NewSpinner returns a spinner, with its own Progress which writes to w.
+// The progress is stopped when the spinner is done.
+func NewSpinner(w io.Writer, name string) *Bar {
+	p := NewProgress(w)
+	p.single = true
+	return p.AddSpinner(name)
+}
+
+// Add adds n to the work done.
+func (b *Bar) Add(n int64) { atomic.AddInt64(&b.current, n) }
+
+// Increment adds 1 to the work done.
+func (b *Bar) Increment() { atomic.AddInt64(&b.current, 1) }
+
+// Set sets the work done.
+func (b *Bar) Set(n int64) { atomic.StoreInt64(&b.current, n) }
+
+// Done marks the task as finished.
+func (b *Bar) Done() {
+	if b.isDone() {
+		return
+	}
+	b.end.Store(time.Now())
+	if b.p.single {
+		b.p.Stop()
+	}
+}
+
+func (b *Bar) isDone() bool { return b.end.Load() != nil }
+
+// line returns the state of the task. A negative frame is used to print
+// the state without animation.
+func (b *Bar) line(now time.Time, frame int) string {
+	current := atomic.LoadInt64(&b.current)
+	if end, ok := b.end.Load().(time.Time); ok {
+		now = end
+	}
+	elapsed := now.Sub(b.start)
+
+	rate := ""
+	if secs := elapsed.Seconds(); secs > 0 {
+		rate = fmt.Sprintf("  %.1f/s", float64(current)/secs)
+	}
+
+	if b.total < 0 { // spinner
+		state := "done"
+		if !b.isDone() {
+			state = "..."
+			if frame >= 0 {
+				state = spinnerFrames[frame%len(spinnerFrames)]
+			}
+		}
+		return fmt.Sprintf("%s %s %d%s  %s", b.name, state, current, rate,
+			elapsed.Round(time.Second))
+	}
+
+	percent := 100.0
+	if b.total > 0 {
+		percent = float64(current) * 100 / float64(b.total)
+		if percent > 100 {
+			percent = 100
+		}
+	}
+
+	eta := ""
+	if b.isDone() {
+		eta = "  " + elapsed.Round(time.Second).String()
+	} else if current > 0 && current < b.total {
+		left := time.Duration(float64(elapsed) * float64(b.total-current) / float64(current))
+		eta = "  ETA " + left.Round(time.Second).String()
+	}
+
+	if frame < 0 {
+		return fmt.Sprintf("%s: %d/%d (%.0f%%)%s%s", b.name, current, b.total,
+			percent, rate, eta)
+	}
+
+	filled := int(percent * barWidth / 100)
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %d/%d %3.0f%%%s%s", b.name, bar, current, b.total,
+		percent, rate, eta)
+}
@@ -0,0 +1,46 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf)
+
+	bar := p.AddBar("files", 10)
+	spin := p.AddSpinner("search")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bar.Increment()
+			spin.Add(2)
+		}()
+	}
+	wg.Wait()
+	bar.Done()
+	p.Stop()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "files: 10/10 (100%)") {
+		t.Errorf("unexpected line for bar: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "search ... 20") {
+		t.Errorf("unexpected line for spinner: %q", lines[1])
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tredoe/goutil/internal/term"
+)
+
+// A Prompter asks questions to the user, reading the answers from In.
+// The questions are written to Out with the prefix StdoutPrefix, and the
+// messages about invalid answers to Err with the prefix StderrPrefix; Err is
+// os.Stderr if it is nil.
+type Prompter struct {
+	In           io.Reader
+	Out          io.Writer
+	Err          io.Writer
+	StdoutPrefix string
+	StderrPrefix string
+
+	r      *bufio.Reader
+	rIn    io.Reader // the reader buffered in r
+	global bool      // use the global prefixes and standard files
+}
+
+// NewPrompter returns a Prompter which reads from in and writes the questions
+// to out, with the prefixes by default. The messages about invalid answers are
+// written to os.Stderr, unless Err is set.
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{
+		In:           in,
+		Out:          out,
+		StdoutPrefix: " * ",
+		StderrPrefix: " [!] ",
+	}
+}
+
+// stdPrompter is the Prompter used by the package-level functions.
+// It uses the variables StdoutPrefix and StderrPrefix, and the files
+// os.Stdin, os.Stdout and os.Stderr at the time of asking.
+var stdPrompter = &Prompter{global: true}
+
+func (p *Prompter) files() (in io.Reader, out io.Writer) {
+	if p.global {
+		return os.Stdin, os.Stdout
+	}
+	return p.In, p.Out
+}
+
+func (p *Prompter) errWriter() io.Writer {
+	if p.global || p.Err == nil {
+		return os.Stderr
+	}
+	return p.Err
+}
+
+func (p *Prompter) ask(question string) {
+	_, out := p.files()
+	prefix := p.StdoutPrefix
+	if p.global {
+		prefix = StdoutPrefix
+	}
+	fmt.Fprintf(out, "%s%s", prefix, question)
+}
+
+func (p *Prompter) invalid(msg string) {
+	prefix := p.StderrPrefix
+	if p.global {
+		prefix = StderrPrefix
+	}
+	fmt.Fprintf(p.errWriter(), "%s%s\n", prefix, msg)
+}
+
+// readLine reads a line, without the end of line.
+// It returns io.EOF if there is no more input.
+func (p *Prompter) readLine() (string, error) {
+	in, _ := p.files()
+	if p.r == nil || p.rIn != in {
+		p.r = bufio.NewReader(in)
+		p.rIn = in
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Confirm asks a question whose answer is yes or no, returning def if the
+// answer is empty.
+func (p *Prompter) Confirm(question string, def bool) (bool, error) {
+	options := "[y/N]"
+	if def {
+		options = "[Y/n]"
+	}
+
+	for {
+		p.ask(question + " " + options + " ")
+		answer, err := p.readLine()
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		p.invalid("Please answer yes or no.")
+	}
+}
+
+// Prompt asks a question, returning def if the answer is empty.
+// If validate is not nil, the question is asked again while the answer is
+// not valid.
+func (p *Prompter) Prompt(question, def string, validate func(answer string) error) (string, error) {
+	if def != "" {
+		question += " [" + def + "]"
+	}
+
+	for {
+		p.ask(question + ": ")
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+
+		if answer = strings.TrimSpace(answer); answer == "" {
+			answer = def
+		}
+		if validate != nil {
+			if err = validate(answer); err != nil {
+				p.invalid(err.Error())
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// Password asks for a password, without echo of the characters typed if the
+// input is a terminal.
+func (p *Prompter) Password(question string) (string, error) {
+	in, out := p.files()
+	p.ask(question + ": ")
+
+	if term.IsTerminal(in) {
+		restore, err := term.DisableEcho(in)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			restore()
+			fmt.Fprintln(out) // the newline typed is not shown
+		}()
+	}
+	return p.readLine()
+}
+
+// Select asks to choose one of the options, returning its index.
+// The answer can be the number of the option or the option itself.
+// If def is a valid index, it is returned if the answer is empty.
+func (p *Prompter) Select(question string, options []string, def int) (int, error) {
+	if len(options) == 0 {
+		return -1, errors.New("no options to select")
+	}
+	hasDefault := def >= 0 && def < len(options)
+
+	_, out := p.files()
+	p.ask(question + "\n")
+	for i, v := range options {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, v)
+	}
+	if hasDefault {
+		question = "Choose an option [" + strconv.Itoa(def+1) + "]: "
+	} else {
+		question = "Choose an option: "
+	}
+
+	for {
+		p.ask(question)
+		answer, err := p.readLine()
+		if err != nil {
+			return -1, err
+		}
+
+		answer = strings.TrimSpace(answer)
+		if answer == "" && hasDefault {
+			return def, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		for i, v := range options {
+			if v == answer {
+				return i, nil
+			}
+		}
+		p.invalid(fmt.Sprintf("Please enter a number from 1 to %d.", len(options)))
+	}
+}
+
+// * * *
+
+// Confirm asks a question from the standard input; see (*Prompter).Confirm.
+func Confirm(question string, def bool) (bool, error) {
+	return stdPrompter.Confirm(question, def)
+}
+
+// Prompt asks a question from the standard input; see (*Prompter).Prompt.
+func Prompt(question, def string, validate func(answer string) error) (string, error) {
+	return stdPrompter.Prompt(question, def, validate)
+}
+
+// Password asks for a password from the standard input; see (*Prompter).Password.
+func Password(question string) (string, error) {
+	return stdPrompter.Password(question)
+}
+
+// Select asks to choose an option from the standard input; see (*Prompter).Select.
+func Select(question string, options []string, def int) (int, error) {
+	return stdPrompter.Select(question, options, def)
+}
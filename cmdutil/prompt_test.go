@@ -0,0 +1,77 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPrompter(t *testing.T) {
+	in := strings.NewReader("maybe\nyes\n\nabc\n42\nsecret\n5\nbar\n")
+	var out, errOut bytes.Buffer
+	p := NewPrompter(in, &out)
+	p.Err = &errOut
+
+	ok, err := p.Confirm("Continue?", false)
+	if err != nil || !ok {
+		t.Errorf("Confirm: got %v, %v", ok, err)
+	}
+
+	name, err := p.Prompt("Name", "Joe", nil)
+	if err != nil || name != "Joe" {
+		t.Errorf("Prompt: got %q, %v", name, err)
+	}
+
+	isNumber := func(s string) error {
+		if strings.Trim(s, "0123456789") != "" {
+			return errors.New("not a number")
+		}
+		return nil
+	}
+	age, err := p.Prompt("Age", "", isNumber)
+	if err != nil || age != "42" {
+		t.Errorf("Prompt: got %q, %v", age, err)
+	}
+
+	pass, err := p.Password("Password")
+	if err != nil || pass != "secret" {
+		t.Errorf("Password: got %q, %v", pass, err)
+	}
+
+	i, err := p.Select("Pick one", []string{"foo", "bar"}, 0)
+	if err != nil || i != 1 {
+		t.Errorf("Select: got %d, %v", i, err)
+	}
+
+	if _, err = p.Confirm("Again?", true); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	want := " * Continue? [y/N] " +
+		" * Continue? [y/N] " +
+		" * Name [Joe]: " +
+		" * Age: " +
+		" * Age: " +
+		" * Password: " +
+		" * Pick one\n  1) foo\n  2) bar\n" +
+		" * Choose an option [1]: " +
+		" * Choose an option [1]: " +
+		" * Again? [Y/n] "
+	if got := out.String(); got != want {
+		t.Errorf("stdout: got:\n%q\nwant:\n%q", got, want)
+	}
+	want = " [!] Please answer yes or no.\n" +
+		" [!] not a number\n" +
+		" [!] Please enter a number from 1 to 2.\n"
+	if got := errOut.String(); got != want {
+		t.Errorf("stderr: got:\n%q\nwant:\n%q", got, want)
+	}
+}
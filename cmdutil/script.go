@@ -0,0 +1,365 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// RunScripts runs the script files "testdata/script/*.txtar" against the
+// command in the given directory, which is built with the options o.
+// Every script is run as a parallel subtest, named like the file without
+// its extension.
+//
+// A script is a txtar archive: its comment holds the commands, one per line,
+// and its files are created into a temporary directory, which is the working
+// directory at starting, and whose path is set in the variable $WORK.
+//
+//	# the command echoes its arguments
+//	exec echo a b
+//	stdout '^a\|b$'
+//	! stderr .
+//
+//	exec cat in.txt
+//	stdout foo
+//
+//	-- in.txt --
+//	foo
+//
+// The commands are:
+//
+//	exec args...    run the command under test with the arguments
+//	stdin file      use the file as standard input of the next exec
+//	stdout regexp   check that the standard output of the last exec matches
+//	stderr regexp   check that the standard error of the last exec matches
+//	env key=value   set environment variables
+//	cd dir          change the working directory
+//
+// A command prefixed by "!" is expected to fail: for exec, to exit with a
+// status other than 0; for stdout and stderr, to not match. An exec without
+// "!" which fails makes the script to fail.
+// The lines are split in arguments like in SplitArgs, and the variables like
+// $NAME or ${NAME} are expanded in every argument. The regular expressions
+// are matched in multi-line mode. The empty lines and the ones starting with
+// '#' are skipped.
+func (o *BuildOptions) RunScripts(t *testing.T, dir string) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "script", "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scripts found in testdata/script")
+	}
+
+	cmdPath, cmdName := o.build(t, dir)
+
+	for _, file := range files {
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".txtar")
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &script{
+				t:       t,
+				file:    file,
+				cmdPath: cmdPath,
+				cmdName: cmdName,
+			}
+			s.run(data)
+		})
+	}
+}
+
+// RunScripts runs the script files "testdata/script/*.txtar" against the
+// command in the given directory. See (*BuildOptions).RunScripts.
+func RunScripts(t *testing.T, dir string) {
+	t.Helper()
+	defaultBuild.RunScripts(t, dir)
+}
+
+// script holds the state of a script being run.
+type script struct {
+	t       *testing.T
+	file    string
+	line    int // line of the command being run
+	cmdPath string
+	cmdName string
+
+	workDir string
+	dir     string
+	env     []string
+	stdin   string // file for the next exec
+
+	stdout, stderr string // outputs of the last exec
+}
+
+// run runs the script in data.
+func (s *script) run(data []byte) {
+	comment, files := parseArchive(data)
+
+	s.workDir = s.t.TempDir()
+	s.dir = s.workDir
+	s.env = append(os.Environ(), "WORK="+s.workDir)
+
+	for _, f := range files {
+		if err := createFiles(s.workDir, map[string]string{f.name: f.data}); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	for i, line := range strings.Split(comment, "\n") {
+		s.line = i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		neg := false
+		if line[0] == '!' {
+			neg = true
+			line = strings.TrimSpace(line[1:])
+		}
+		args, err := SplitArgs(line)
+		if err != nil {
+			s.fatalf("%s", err)
+		}
+		if len(args) == 0 {
+			s.fatalf("missing command after '!'")
+		}
+		for i := range args {
+			args[i] = os.Expand(args[i], s.getenv)
+		}
+
+		switch args[0] {
+		case "exec":
+			s.exec(neg, args[1:])
+		case "stdin":
+			s.checkArgs(neg, args, 1)
+			s.stdin = s.path(args[1])
+		case "stdout":
+			s.checkArgs(false, args, 1)
+			s.match(neg, "stdout", s.stdout, args[1])
+		case "stderr":
+			s.checkArgs(false, args, 1)
+			s.match(neg, "stderr", s.stderr, args[1])
+		case "env":
+			s.checkArgs(neg, args, -1)
+			for _, v := range args[1:] {
+				if !strings.Contains(v, "=") {
+					s.fatalf("env: expected key=value, got %q", v)
+				}
+				s.env = append(s.env, v)
+			}
+		case "cd":
+			s.checkArgs(neg, args, 1)
+			dir := s.path(args[1])
+			if info, err := os.Stat(dir); err != nil {
+				s.fatalf("%s", err)
+			} else if !info.IsDir() {
+				s.fatalf("cd: %s is not a directory", args[1])
+			}
+			s.dir = dir
+		default:
+			s.fatalf("unknown command %q", args[0])
+		}
+	}
+}
+
+// exec runs the command under test with the given arguments.
+func (s *script) exec(neg bool, args []string) {
+	s.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.cmdPath, args...)
+	cmd.Args[0] = s.cmdName
+	cmd.WaitDelay = waitDelay
+	cmd.Dir = s.dir
+	cmd.Env = s.env
+
+	var bufStdout, bufStderr bytes.Buffer
+	cmd.Stdout = &bufStdout
+	cmd.Stderr = &bufStderr
+
+	if s.stdin != "" {
+		f, err := os.Open(s.stdin)
+		if err != nil {
+			s.fatalf("%s", err)
+		}
+		defer f.Close()
+		cmd.Stdin = f
+		s.stdin = ""
+	}
+
+	coverDir, err := setCoverDir(cmd)
+	if err != nil {
+		s.fatalf("%s", err)
+	}
+	if coverDir != "" {
+		defer func() {
+			if err := mergeCoverage(coverDir); err != nil {
+				s.t.Errorf("%s:%d: %s", s.file, s.line, err)
+			}
+		}()
+	}
+
+	err = cmd.Run()
+	s.stdout, s.stderr = bufStdout.String(), bufStderr.String()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		s.fatalf("exec: command killed after %s", DefaultTimeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			s.fatalf("%s", err)
+		}
+	}
+
+	switch {
+	case neg && err == nil:
+		s.logOutput()
+		s.fatalf("exec: unexpected success")
+	case !neg && err != nil:
+		s.logOutput()
+		s.fatalf("exec: %s", err)
+	}
+}
+
+// match checks whether the output matches the regular expression pattern.
+func (s *script) match(neg bool, name, output, pattern string) {
+	s.t.Helper()
+	re, err := regexp.Compile("(?m)" + pattern)
+	if err != nil {
+		s.fatalf("%s: %s", name, err)
+	}
+
+	if re.MatchString(output) == neg {
+		s.logOutput()
+		if neg {
+			s.fatalf("%s: unexpected match for %q", name, pattern)
+		}
+		s.fatalf("%s: no match for %q", name, pattern)
+	}
+}
+
+// checkArgs fails if the command was prefixed by '!' and that is not allowed,
+// or if the number of arguments is not n; -1 means at least one.
+func (s *script) checkArgs(neg bool, args []string, n int) {
+	s.t.Helper()
+	if neg {
+		s.fatalf("%s: unsupported '!'", args[0])
+	}
+	if n == -1 && len(args) < 2 {
+		s.fatalf("%s: missing arguments", args[0])
+	}
+	if n != -1 && len(args)-1 != n {
+		s.fatalf("%s: expected %d argument(s), got %d", args[0], n, len(args)-1)
+	}
+}
+
+// getenv returns the value of the variable key in the environment of the
+// script.
+func (s *script) getenv(key string) string {
+	for i := len(s.env) - 1; i >= 0; i-- {
+		if k, v, _ := strings.Cut(s.env[i], "="); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// path returns name relative to the working directory of the script.
+func (s *script) path(name string) string {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// logOutput logs the outputs of the last exec.
+func (s *script) logOutput() {
+	s.t.Helper()
+	if s.stdout != "" {
+		s.t.Logf("[stdout]\n%s", s.stdout)
+	}
+	if s.stderr != "" {
+		s.t.Logf("[stderr]\n%s", s.stderr)
+	}
+}
+
+// fatalf reports an error at the line of the command being run, and stops the
+// script.
+func (s *script) fatalf(format string, args ...interface{}) {
+	s.t.Helper()
+	s.t.Fatalf("%s:%d: %s", s.file, s.line, fmt.Sprintf(format, args...))
+}
+
+// * * *
+
+// archiveFile is a file of a txtar archive.
+type archiveFile struct {
+	name string
+	data string
+}
+
+// parseArchive parses the txtar archive in data, returning its comment and
+// its files. A file starts at a line like "-- name --", and ends at the next
+// one.
+func parseArchive(data []byte) (comment string, files []archiveFile) {
+	lines := strings.SplitAfter(string(data), "\n")
+	var b strings.Builder
+	var cur *archiveFile
+
+	flush := func() {
+		if cur == nil {
+			comment = b.String()
+		} else {
+			cur.data = b.String()
+			files = append(files, *cur)
+		}
+		b.Reset()
+	}
+
+	for _, line := range lines {
+		if name, ok := fileMarker(line); ok {
+			flush()
+			cur = &archiveFile{name: name}
+			continue
+		}
+		b.WriteString(line)
+	}
+	flush()
+	return comment, files
+}
+
+// fileMarker reports whether line is a file marker, returning the file name.
+func fileMarker(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 6 || !strings.HasPrefix(line, "-- ") || !strings.HasSuffix(line, " --") {
+		return "", false
+	}
+	name := strings.TrimSpace(line[3 : len(line)-3])
+	return name, name != ""
+}
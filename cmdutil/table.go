@@ -0,0 +1,528 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tredoe/goutil/internal/term"
+)
+
+// TableFormat is the format to render a Table.
+// It can be used as a flag.Value, like "--format=text|csv|tsv|json|markdown".
+type TableFormat int
+
+// Formats of table.
+const (
+	FormatText TableFormat = iota // aligned columns; by default
+	FormatCSV
+	FormatTSV
+	FormatJSON
+	FormatMarkdown
+)
+
+var tableFormatNames = [...]string{
+	FormatText:     "text",
+	FormatCSV:      "csv",
+	FormatTSV:      "tsv",
+	FormatJSON:     "json",
+	FormatMarkdown: "markdown",
+}
+
+// String returns the name of the format.
+func (f TableFormat) String() string {
+	if f >= 0 && int(f) < len(tableFormatNames) {
+		return tableFormatNames[f]
+	}
+	return fmt.Sprintf("TableFormat(%d)", int(f))
+}
+
+// Set sets the format from its name.
+func (f *TableFormat) Set(s string) error {
+	if s == "md" {
+		s = "markdown"
+	}
+	for i, v := range tableFormatNames {
+		if v == s {
+			*f = TableFormat(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q: must be text, csv, tsv, json or markdown", s)
+}
+
+// Align is the alignment of a column.
+type Align int
+
+// Alignments
+const (
+	AlignAuto  Align = iota // to the right if all values are numbers; by default
+	AlignLeft               // to the left
+	AlignRight              // to the right
+)
+
+const columnSep = "  "
+
+type cell struct {
+	text  string
+	value interface{}
+}
+
+// A Table formats rows of values in columns.
+type Table struct {
+	// Width is the maximum width of every line in format text; if it is 0,
+	// the width of the terminal is used when the writer is a terminal.
+	// The values which do not fit are truncated, or wrapped if Wrap is true.
+	Width int
+	Wrap  bool
+
+	headers []string
+	aligns  []Align
+	rows    [][]cell
+}
+
+// NewTable returns a table with the given headers, which can be empty.
+func NewTable(headers ...string) *Table {
+	return &Table{headers: headers}
+}
+
+// SetAlign sets the alignment of the column number col, starting at 0.
+func (t *Table) SetAlign(col int, align Align) *Table {
+	for len(t.aligns) <= col {
+		t.aligns = append(t.aligns, AlignAuto)
+	}
+	t.aligns[col] = align
+	return t
+}
+
+// AddRow adds a row with the given values, formatted like fmt.Sprint.
+func (t *Table) AddRow(values ...interface{}) {
+	row := make([]cell, len(values))
+	for i, v := range values {
+		row[i] = cell{fmt.Sprint(v), v}
+	}
+	t.rows = append(t.rows, row)
+}
+
+// numCols returns the number of columns.
+func (t *Table) numCols() int {
+	n := len(t.headers)
+	for _, row := range t.rows {
+		if len(row) > n {
+			n = len(row)
+		}
+	}
+	return n
+}
+
+// rightAligned reports whether the column col is aligned to the right.
+func (t *Table) rightAligned(col int) bool {
+	if col < len(t.aligns) && t.aligns[col] != AlignAuto {
+		return t.aligns[col] == AlignRight
+	}
+
+	hasNumber := false
+	for _, row := range t.rows {
+		if col >= len(row) || row[col].text == "" {
+			continue
+		}
+		if !isNumber(row[col]) {
+			return false
+		}
+		hasNumber = true
+	}
+	return hasNumber
+}
+
+// Render writes the table to w in the given format.
+func (t *Table) Render(w io.Writer, format TableFormat) error {
+	switch format {
+	case FormatText:
+		return t.renderText(w)
+	case FormatCSV:
+		return t.renderCSV(w)
+	case FormatTSV:
+		return t.renderTSV(w)
+	case FormatJSON:
+		return t.renderJSON(w)
+	case FormatMarkdown:
+		return t.renderMarkdown(w)
+	}
+	return fmt.Errorf("invalid table format: %s", format)
+}
+
+func (t *Table) renderText(w io.Writer) error {
+	nCols := t.numCols()
+	if nCols == 0 {
+		return nil
+	}
+
+	widths := make([]int, nCols)
+	right := make([]bool, nCols)
+	for i := range widths {
+		if i < len(t.headers) {
+			widths[i] = textWidth(t.headers[i])
+		}
+		right[i] = t.rightAligned(i)
+	}
+	for _, row := range t.rows {
+		for i, c := range row {
+			if n := textWidth(c.text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	maxWidth := t.Width
+	if maxWidth == 0 && term.IsTerminal(w) {
+		maxWidth, _ = term.Width(w)
+	}
+	if maxWidth > 0 {
+		shrink(widths, maxWidth-len(columnSep)*(nCols-1))
+	}
+
+	var b bytes.Buffer
+	writeRow := func(cells []string) {
+		// Split the cells in the lines which fit in the column.
+		lines := make([][]string, nCols)
+		height := 1
+		for i := range lines {
+			s := ""
+			if i < len(cells) {
+				s = cells[i]
+			}
+			if t.Wrap {
+				lines[i] = wrapText(s, widths[i])
+			} else {
+				lines[i] = []string{truncateText(s, widths[i])}
+			}
+			if len(lines[i]) > height {
+				height = len(lines[i])
+			}
+		}
+
+		for n := 0; n < height; n++ {
+			var line strings.Builder
+			for i := range lines {
+				s := ""
+				if n < len(lines[i]) {
+					s = lines[i][n]
+				}
+				pad := strings.Repeat(" ", widths[i]-textWidth(s))
+				if i != 0 {
+					line.WriteString(columnSep)
+				}
+				if right[i] {
+					line.WriteString(pad + s)
+				} else {
+					line.WriteString(s + pad)
+				}
+			}
+			b.WriteString(strings.TrimRight(line.String(), " "))
+			b.WriteByte('\n')
+		}
+	}
+
+	if len(t.headers) != 0 {
+		writeRow(t.headers)
+	}
+	for _, row := range t.rows {
+		writeRow(texts(row))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (t *Table) renderCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if len(t.headers) != 0 {
+		cw.Write(t.headers)
+	}
+	for _, row := range t.rows {
+		cw.Write(texts(row))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (t *Table) renderTSV(w io.Writer) error {
+	// The tabs and newlines are not allowed into the values.
+	clean := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ")
+
+	var b bytes.Buffer
+	writeRow := func(cells []string) {
+		for i, s := range cells {
+			if i != 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(clean.Replace(s))
+		}
+		b.WriteByte('\n')
+	}
+
+	if len(t.headers) != 0 {
+		writeRow(t.headers)
+	}
+	for _, row := range t.rows {
+		writeRow(texts(row))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// renderJSON writes an array of objects keyed by the headers, or an array of
+// arrays if there are no headers. The numbers are kept as JSON numbers.
+// The cells missing in a row are null, and a row with more cells than headers
+// is an error.
+func (t *Table) renderJSON(w io.Writer) error {
+	rows := make([]interface{}, len(t.rows))
+
+	for i, row := range t.rows {
+		values := make([]interface{}, len(row))
+		for j, c := range row {
+			if isJSONNumber(c) {
+				values[j] = json.Number(c.text)
+			} else {
+				values[j] = c.text
+			}
+		}
+
+		if len(t.headers) == 0 {
+			rows[i] = values
+			continue
+		}
+		if len(values) > len(t.headers) {
+			return fmt.Errorf("table: row %d has %d cells, but there are %d headers",
+				i, len(values), len(t.headers))
+		}
+		// Use an ordered object to keep the order of the headers.
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for j := range t.headers {
+			var v interface{}
+			if j < len(values) {
+				v = values[j]
+			}
+			if j != 0 {
+				b.WriteByte(',')
+			}
+			key, _ := json.Marshal(t.headers[j])
+			val, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(val)
+		}
+		b.WriteByte('}')
+		rows[i] = json.RawMessage(b.Bytes())
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func (t *Table) renderMarkdown(w io.Writer) error {
+	nCols := t.numCols()
+	if nCols == 0 {
+		return nil
+	}
+	escape := strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>")
+
+	var b bytes.Buffer
+	writeRow := func(cells []string) {
+		b.WriteByte('|')
+		for i := 0; i < nCols; i++ {
+			s := ""
+			if i < len(cells) {
+				s = escape.Replace(cells[i])
+			}
+			b.WriteString(" " + s + " |")
+		}
+		b.WriteByte('\n')
+	}
+
+	// A table in Markdown requires a header.
+	headers := t.headers
+	if len(headers) == 0 {
+		headers = make([]string, nCols)
+	}
+	writeRow(headers)
+
+	b.WriteByte('|')
+	for i := 0; i < nCols; i++ {
+		if t.rightAligned(i) {
+			b.WriteString(" ---: |")
+		} else {
+			b.WriteString(" --- |")
+		}
+	}
+	b.WriteByte('\n')
+
+	for _, row := range t.rows {
+		writeRow(texts(row))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// * * *
+
+func texts(row []cell) []string {
+	s := make([]string, len(row))
+	for i, c := range row {
+		s[i] = c.text
+	}
+	return s
+}
+
+// isNumber reports whether the cell is a number.
+func isNumber(c cell) bool {
+	if isNumberValue(c.value) {
+		return true
+	}
+	_, err := strconv.ParseFloat(c.text, 64)
+	return err == nil
+}
+
+// isJSONNumber reports whether the cell is of a numeric type, and its text is
+// a valid JSON number. That is not the case of the types with their own
+// format, like time.Duration, nor of the floats NaN and Inf.
+func isJSONNumber(c cell) bool {
+	if !isNumberValue(c.value) {
+		return false
+	}
+	if _, err := strconv.ParseFloat(c.text, 64); err != nil {
+		return false
+	}
+	return json.Valid([]byte(c.text))
+}
+
+// isNumberValue reports whether v is of a numeric type.
+func isNumberValue(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// shrink reduces the widest columns until the sum of widths fits in max.
+// Every column keeps a width of 3 at least.
+func shrink(widths []int, max int) {
+	const minWidth = 3
+
+	total := 0
+	for _, v := range widths {
+		total += v
+	}
+	for total > max {
+		widest := 0
+		for i, v := range widths {
+			if v > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minWidth {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+// wideRanges are the ranges of runes which use two columns.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// runeWidth returns the number of columns used by r in a terminal.
+func runeWidth(r rune) int {
+	if r == 0 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, v := range wideRanges {
+		if r >= v[0] && r <= v[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// textWidth returns the number of columns used by s in a terminal.
+func textWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// truncateText truncates s to the width given, ending it in "…".
+func truncateText(s string, width int) string {
+	if textWidth(s) <= width {
+		return s
+	}
+	n := 0
+	for i, r := range s {
+		if n+runeWidth(r) > width-1 {
+			return s[:i] + "…"
+		}
+		n += runeWidth(r)
+	}
+	return s
+}
+
+// wrapText splits s in lines of the width given, at spaces if it is possible.
+func wrapText(s string, width int) []string {
+	var lines []string
+
+	for textWidth(s) > width {
+		n, cut, lastSpace := 0, 0, -1
+		for i, r := range s {
+			if n+runeWidth(r) > width {
+				cut = i
+				break
+			}
+			if r == ' ' {
+				lastSpace = i
+			}
+			n += runeWidth(r)
+		}
+		if cut == 0 { // a rune wider than the column
+			_, cut = utf8.DecodeRuneInString(s)
+		}
+
+		if lastSpace > 0 {
+			lines = append(lines, s[:lastSpace])
+			s = s[lastSpace+1:]
+		} else {
+			lines = append(lines, s[:cut])
+			s = s[cut:]
+		}
+	}
+	return append(lines, s)
+}
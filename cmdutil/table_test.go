@@ -0,0 +1,127 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTable(t *testing.T) {
+	tb := NewTable("NAME", "PORT", "DESCRIPTION")
+	tb.AddRow("web", 80, "front-end server")
+	tb.AddRow("日本", 8080, "a | b")
+
+	tests := []struct {
+		format TableFormat
+		want   string
+	}{
+		{FormatText, "" +
+			"NAME  PORT  DESCRIPTION\n" +
+			"web     80  front-end server\n" +
+			"日本  8080  a | b\n",
+		},
+		{FormatCSV, "NAME,PORT,DESCRIPTION\nweb,80,front-end server\n日本,8080,a | b\n"},
+		{FormatTSV, "NAME\tPORT\tDESCRIPTION\nweb\t80\tfront-end server\n日本\t8080\ta | b\n"},
+		{FormatJSON, `[
+  {
+    "NAME": "web",
+    "PORT": 80,
+    "DESCRIPTION": "front-end server"
+  },
+  {
+    "NAME": "日本",
+    "PORT": 8080,
+    "DESCRIPTION": "a | b"
+  }
+]
+`},
+		{FormatMarkdown, "" +
+			"| NAME | PORT | DESCRIPTION |\n" +
+			"| --- | ---: | --- |\n" +
+			"| web | 80 | front-end server |\n" +
+			"| 日本 | 8080 | a \\| b |\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tb.Render(&buf, tt.format); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tt.format, got, tt.want)
+		}
+	}
+
+	tb.Width = 20
+	var buf bytes.Buffer
+	tb.Render(&buf, FormatText)
+	want := "" +
+		"NAME  PORT  DESCRIP…\n" +
+		"web     80  front-e…\n" +
+		"日本  8080  a | b\n"
+	if got := buf.String(); got != want {
+		t.Errorf("truncated: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	tb.Wrap = true
+	buf.Reset()
+	tb.Render(&buf, FormatText)
+	want = "" +
+		"NAME  PORT  DESCRIPT\n" +
+		"            ION\n" +
+		"web     80  front-en\n" +
+		"            d server\n" +
+		"日本  8080  a | b\n"
+	if got := buf.String(); got != want {
+		t.Errorf("wrapped: got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTableJSON(t *testing.T) {
+	tb := NewTable()
+	tb.AddRow(2*time.Second, os.FileMode(0644), math.NaN(), math.Inf(1), 1.5, uint8(3))
+
+	var buf bytes.Buffer
+	if err := tb.Render(&buf, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  [
+    "2s",
+    "-rw-r--r--",
+    "NaN",
+    "+Inf",
+    1.5,
+    3
+  ]
+]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	tb = NewTable("A", "B")
+	tb.AddRow(1)
+	buf.Reset()
+	if err := tb.Render(&buf, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	want = "[\n  {\n    \"A\": 1,\n    \"B\": null\n  }\n]\n"
+	if got := buf.String(); got != want {
+		t.Errorf("short row: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	tb.AddRow(1, 2, 3)
+	if err := tb.Render(&buf, FormatJSON); err == nil {
+		t.Error("long row: expected an error")
+	}
+}
@@ -8,91 +8,299 @@ package cmdutil
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"time"
 
 	"github.com/tredoe/goutil/merrors"
 )
 
+// UpdateGolden indicates whether the golden files are rewritten with the
+// outputs of the commands, instead of being compared with them.
+// It is set by the flag -update of the test binaries which import the package
+// "github.com/tredoe/goutil/cmdutil/cmdtest".
+var UpdateGolden bool
+
 // CommandInfo represents the command for testing.
 type CommandInfo struct {
-	Args   string // the arguments after of the command
+	Name   string // name of the subtest in RunCommands; its index by default
+	Args   string // the arguments after of the command, split like in SplitArgs
 	In     string // in the event that the command needs to read the input
 	Out    string // output expected
 	Stderr string // error expected
+
+	// Argv are the arguments after of the command, used instead of Args if it
+	// is not nil.
+	Argv []string
+
+	// OutMatch and StderrMatch check the outputs, instead of the fields Out
+	// and Stderr, if they are not nil.
+	OutMatch    Matcher
+	StderrMatch Matcher
+
+	// Normalize indicates the changes applied to the outputs, and to the
+	// outputs expected, before of comparing them.
+	Normalize Normalize
+
+	// Golden is the name of the golden files which hold the outputs expected,
+	// instead of the fields Out and Stderr: "testdata/<Golden>.golden" for
+	// the standard output, and "testdata/<Golden>.stderr.golden" for the
+	// standard error; a missing file is an empty output.
+	// The files are rewritten if UpdateGolden is set.
+	Golden string
+
+	// ExitCode is the exit status expected; -1 if the command is killed by a
+	// signal.
+	ExitCode int
+
+	// AnyExitCode disables the check of the exit status.
+	AnyExitCode bool
+
+	// Env is the environment of the command; like in exec.Cmd, if it is nil,
+	// the command uses the environment of the current process.
+	Env []string
+
+	// Dir is the working directory of the command. If it is empty, the command
+	// runs in the current directory, or in a temporary directory if there are
+	// Files. It can not be used together with Files.
+	Dir string
+
+	// Timeout is the maximum time to run the command; 1 minute by default.
+	Timeout time.Duration
+
+	// Files maps the paths of files, relative to the working directory, to
+	// their content. They are created into a temporary directory, which is
+	// the working directory of the command, and removed after the run.
+	Files map[string]string
 }
 
-// TestCommand tests whether a command in the given directory returns the
-// expected strings for both standard output and error.
-//
-// Returns an error implemented in package "github.com/tredoe/goutil/merrors".
-func TestCommand(dir string, tests []CommandInfo) error {
-	cmdFile := fmt.Sprintf(".%c_cmd_", filepath.Separator)
+// DefaultTimeout is the maximum time to run a command if CommandInfo.Timeout
+// is not set.
+const DefaultTimeout = time.Minute
 
-	if dir == "" || dir == "." {
-		wd, err := os.Getwd()
-		if err != nil {
+// waitDelay is the maximum time to wait for the outputs of a command to be
+// closed after it is killed or exits, like when a child process keeps them
+// open.
+const waitDelay = time.Second
+
+// goldenFiles returns the paths of the golden files for standard output and
+// error.
+func (c CommandInfo) goldenFiles() (stdout, stderr string) {
+	base := filepath.Join("testdata", c.Golden)
+	return base + ".golden", base + ".stderr.golden"
+}
+
+// readGolden returns the content of a golden file; an empty string if it does
+// not exist.
+func readGolden(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// writeGolden writes a golden file, removing it if the output is empty.
+func writeGolden(name, output string) error {
+	if output == "" {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
 			return err
 		}
-		cmdFile += filepath.Base(wd)
-
-	} else if dir != "" && dir[0] != '.' && dir[0] != filepath.Separator {
-		dir = "." + string(filepath.Separator) + dir
-		cmdFile += filepath.Base(dir)
+		return nil
 	}
+	return os.WriteFile(name, []byte(output), 0644)
+}
 
-	if runtime.GOOS == "windows" {
-		cmdFile += ".exe"
+// TestCommand tests whether a command in the given directory, built with the
+// options o, returns the expected strings for both standard output and error.
+// The tests are run in sequence; see RunCommands to run them as parallel
+// subtests. The binary is removed before returning.
+//
+// If the test binary is run with coverage enabled, like in "go test -cover",
+// the command can be built with coverage instrumentation; see CoverMain.
+//
+// Returns an error implemented in package "github.com/tredoe/goutil/merrors".
+func (o *BuildOptions) TestCommand(dir string, tests []CommandInfo) error {
+	outDir, err := os.MkdirTemp("", "cmdutil-build-")
+	if err != nil {
+		return err
 	}
+	defer os.RemoveAll(outDir)
 
-	// "go build" generates an executable binary with the directory name
-	out, err := exec.Command("go", "build", "-o", cmdFile, dir).CombinedOutput()
+	cmdPath, cmdName, release, err := o.cachedBuild(dir, outDir)
 	if err != nil {
-		return fmt.Errorf("%s\n%s", out, err)
+		return err
 	}
+	defer release()
 
 	var listErr merrors.ListError
 
 	for i, tt := range tests {
-		var bufStdout, bufStderr bytes.Buffer
-		cmd := new(exec.Cmd)
+		tt.run(i, cmdPath, cmdName, &listErr)
+	}
+	return listErr.Err()
+}
+
+// TestCommand tests whether a command in the given directory returns the
+// expected strings for both standard output and error.
+// See (*BuildOptions).TestCommand.
+func TestCommand(dir string, tests []CommandInfo) error {
+	return defaultBuild.TestCommand(dir, tests)
+}
 
-		if tt.Args != "" {
-			cmd = exec.Command(cmdFile, strings.Split(tt.Args, " ")...)
-		} else {
-			cmd = exec.Command(cmdFile)
+// run runs the command at path for the test number i, with name as the program
+// name, adding to listErr the differences with the results expected.
+func (tt CommandInfo) run(i int, path, name string, listErr *merrors.ListError) {
+	if tt.Dir != "" && len(tt.Files) != 0 {
+		listErr.Addf("%d. Dir and Files can not be used together", i)
+		return
+	}
+	dir, tmpDir := tt.Dir, ""
+	if len(tt.Files) != 0 {
+		var err error
+		if tmpDir, err = os.MkdirTemp("", "cmdutil-"); err != nil {
+			listErr.Addf("%d. %w", i, err)
+			return
 		}
+		defer os.RemoveAll(tmpDir)
+		dir = tmpDir
+	}
+	if err := createFiles(dir, tt.Files); err != nil {
+		listErr.Addf("%d. %w", i, err)
+		return
+	}
+
+	timeout := tt.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-		cmd.Stdout = &bufStdout
-		cmd.Stderr = &bufStderr
-		if tt.In != "" {
-			cmd.Stdin = strings.NewReader(tt.In)
+	args := tt.Argv
+	if args == nil {
+		var err error
+		if args, err = SplitArgs(tt.Args); err != nil {
+			listErr.Addf("%d. Args => %w", i, err)
+			return
 		}
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Args[0] = name
+	cmd.WaitDelay = waitDelay
 
-		err = cmd.Run()
-		cmdStderr := bufStderr.String()
-		if err != nil && cmdStderr == "" {
-			return err
+	var bufStdout, bufStderr bytes.Buffer
+	cmd.Stdout = &bufStdout
+	cmd.Stderr = &bufStderr
+	cmd.Env = tt.Env
+	cmd.Dir = dir
+	if tt.In != "" {
+		cmd.Stdin = strings.NewReader(tt.In)
+	}
+
+	coverDir, err := setCoverDir(cmd)
+	if err != nil {
+		listErr.Addf("%d. %w", i, err)
+		return
+	}
+	if coverDir != "" {
+		defer func() {
+			if err := mergeCoverage(coverDir); err != nil {
+				listErr.Addf("%d. %w", i, err)
+			}
+		}()
+	}
+
+	exitCode := 0
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		listErr.Addf("%d. Timeout => command killed after %s\n* * *", i, timeout)
+		return
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			listErr.Addf("%d. %w", i, err)
+			return
 		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	cmdStdout := tt.Normalize.apply(bufStdout.String(), tmpDir)
+	cmdStderr := tt.Normalize.apply(bufStderr.String(), tmpDir)
+	if tt.Golden != "" {
+		checkGolden(listErr, i, tt, cmdStdout, cmdStderr)
+	} else {
+		checkOutput(listErr, i, "Stderr", cmdStderr, tt.Normalize.apply(tt.Stderr, ""), tt.StderrMatch)
+		checkOutput(listErr, i, "Stdout", cmdStdout, tt.Normalize.apply(tt.Out, ""), tt.OutMatch)
+	}
 
-		if cmdStderr != tt.Stderr {
-			listErr.Add(fmt.Sprintf("%d. Stderr => %q\n\n- Want    => %q\n* * *",
-				i, cmdStderr, tt.Stderr))
+	if !tt.AnyExitCode && exitCode != tt.ExitCode {
+		listErr.Add(fmt.Sprintf("%d. Exit code => %d\n\n- Want       => %d\n* * *",
+			i, exitCode, tt.ExitCode))
+	}
+}
+
+// checkOutput checks the output of the test number i, using the matcher m if
+// it is not nil, or else comparing it with want.
+func checkOutput(listErr *merrors.ListError, i int, name, output, want string, m Matcher) {
+	if m != nil {
+		if err := m.Match(output); err != nil {
+			listErr.Add(fmt.Sprintf("%d. %s => %q\n\n- %s\n* * *", i, name, output, err))
 		}
+		return
+	}
+	if output != want {
+		listErr.Add(fmt.Sprintf("%d. %s => %q\n\n- Want    => %q\n* * *",
+			i, name, output, want))
+	}
+}
+
+// createFiles creates the files into the directory dir, with their content.
+func createFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
 
-		cmdStdout := bufStdout.String()
-		if cmdStdout != tt.Out {
-			listErr.Add(fmt.Sprintf("%d. Stdout => %q\n\n- Want    => %q\n* * *",
-				i, cmdStdout, tt.Out))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// checkGolden compares the outputs of the test number i with its golden files,
+// or it rewrites them if UpdateGolden is set.
+func checkGolden(listErr *merrors.ListError, i int, tt CommandInfo, stdout, stderr string) {
+	fileStdout, fileStderr := tt.goldenFiles()
+
+	for _, v := range []struct{ name, file, output string }{
+		{"Stdout", fileStdout, stdout},
+		{"Stderr", fileStderr, stderr},
+	} {
+		if UpdateGolden {
+			if err := writeGolden(v.file, v.output); err != nil {
+				listErr.AddError(err)
+			}
+			continue
+		}
 
-	if err = os.Remove(cmdFile); err != nil {
-		listErr.Add(err.Error())
+		want, err := readGolden(v.file)
+		if err != nil {
+			listErr.AddError(err)
+			continue
+		}
+		want = tt.Normalize.apply(want, "")
+		if d := diff(v.file, v.name, want, v.output); d != "" {
+			listErr.Add(fmt.Sprintf("%d. %s differs from golden file (run with -update to rewrite it)\n%s* * *",
+				i, v.name, d))
+		}
 	}
-	return listErr.Err()
 }
@@ -0,0 +1,399 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tredoe/goutil/merrors"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(CoverMain(m))
+}
+
+func TestCommandInfo(t *testing.T) {
+	cmdsInfo := []CommandInfo{
+		{
+			Args: "echo a b",
+			Out:  "a|b\n",
+		},
+		{
+			Args: "env FOO",
+			Env:  []string{"FOO=bar"},
+			Out:  "bar\n",
+		},
+		{
+			Args:  "cat data/in.txt",
+			Files: map[string]string{"data/in.txt": "foo\n"},
+			Out:   "foo\n",
+		},
+		{
+			Args: "cat",
+			In:   "from stdin\n",
+			Out:  "from stdin\n",
+		},
+		{
+			Args:     "exit 3",
+			Stderr:   "exit with 3\n",
+			ExitCode: 3,
+		},
+
+		{
+			Args: `echo "a b" 'c d' e\ f ""`,
+			Out:  "a b|c d|e f|\n",
+		},
+		{
+			Argv: []string{"echo", "a b", "c"},
+			Out:  "a b|c\n",
+		},
+		{
+			Args:        "exit 2",
+			OutMatch:    MatchRegexp(`^$`),
+			StderrMatch: MatchContains("exit", "2"),
+			ExitCode:    2,
+		},
+		{
+			Args:     "cat",
+			In:       "b\na\n",
+			OutMatch: MatchLines("a\nb\n"),
+		},
+		{
+			Args:      "cat",
+			In:        "foo  \nbar\t\n",
+			Out:       "foo\nbar\n",
+			Normalize: NormTrailingSpace,
+		},
+		{
+			Args:        "cat missing.txt",
+			Files:       map[string]string{"in.txt": ""},
+			StderrMatch: MatchRegexp(`^open missing.txt: `),
+			ExitCode:    1,
+		},
+		{
+			Args:        "exit 5",
+			Stderr:      "exit with 5\n",
+			AnyExitCode: true,
+		},
+		{
+			Args:   "echo a b",
+			Golden: "echo",
+		},
+	}
+
+	if err := TestCommand("testdata", cmdsInfo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	RunCommands(t, "testdata", []CommandInfo{
+		{Name: "echo", Args: "echo a b", Out: "a|b\n"},
+		{Name: "version", Args: "version", Out: "devel false\n"},
+		{Args: "exit 3", Stderr: "exit with 3\n", ExitCode: 3},
+	})
+
+	o := &BuildOptions{
+		Tags:    []string{"cmdutil_tag"},
+		Ldflags: "-X main.version=v1.2.3",
+	}
+	o.RunCommands(t, "testdata", []CommandInfo{
+		{Name: "version", Args: "version", Out: "v1.2.3 true\n"},
+	})
+}
+
+func TestBuildCache(t *testing.T) {
+	o := &BuildOptions{Ldflags: "-X main.version=cached"}
+	path1, name1 := o.build(t, "testdata")
+
+	t.Run("sub", func(t *testing.T) {
+		path2, name2 := o.build(t, "testdata")
+		if path1 == path2 {
+			t.Fatal("expected the binary into the directory of the subtest")
+		}
+		if name1 != name2 || name1 != "./_cmd_testdata" {
+			t.Errorf("names: got %q and %q", name1, name2)
+		}
+
+		info1, err := os.Stat(path1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info2, err := os.Stat(path2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(info1, info2) {
+			t.Error("expected a link to the binary cached")
+		}
+	})
+}
+
+func TestBuildCacheCalls(t *testing.T) {
+	builds := func() int {
+		buildCache.Lock()
+		defer buildCache.Unlock()
+		return buildCache.count
+	}
+	count := builds()
+
+	// A new version for every run of the test, like with flag -count.
+	version := "calls" + strconv.Itoa(count)
+	o := &BuildOptions{Ldflags: "-X main.version=" + version}
+	tests := []CommandInfo{{Args: "version", Out: version + " false\n"}}
+
+	// TestCommand removes its binary.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	if err := o.TestCommand("testdata", tests); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := os.ReadDir(tmp); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && len(files) != 0 {
+		t.Errorf("expected the binary removed, got %d files", len(files))
+	}
+	if n := builds() - count; n != 1 {
+		t.Errorf("expected 1 build, got %d", n)
+	}
+
+	// The binary of the test is linked while it is available.
+	o.build(t, "testdata")
+	for i := 0; i < 2; i++ {
+		if err := o.TestCommand("testdata", tests); err != nil {
+			t.Fatal(err)
+		}
+	}
+	o.RunCommands(t, "testdata", tests)
+	o.RunCommands(t, "testdata", tests)
+
+	if n := builds() - count; n != 2 {
+		t.Errorf("expected 2 builds, got %d", n)
+	}
+}
+
+func TestCommandInfoMismatch(t *testing.T) {
+	cmdsInfo := []CommandInfo{
+		{
+			Args: "exit 4", // unexpected failure without stderr expected
+		},
+		{
+			Args:    "sleep 10s",
+			Timeout: 100 * time.Millisecond,
+		},
+		{
+			Args:   "exit 3", // unexpected failure with the stderr expected
+			Stderr: "exit with 3\n",
+		},
+		{
+			Args:  "echo",
+			Dir:   "testdata",
+			Files: map[string]string{"test-cmd.go": ""},
+		},
+		{
+			Args:    "spawn 10s", // a child keeps the output open
+			Timeout: 100 * time.Millisecond,
+		},
+	}
+
+	start := time.Now()
+	err := TestCommand("testdata", cmdsInfo)
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the commands to be killed, took %s", d)
+	}
+	list, ok := err.(merrors.ListError)
+	if !ok {
+		t.Fatalf("expected a merrors.ListError, got %v", err)
+	}
+	if len(list) != 6 {
+		t.Fatalf("expected 6 errors, got %d:\n%+v", len(list), list)
+	}
+	for i, want := range []string{
+		"0. Stderr", "0. Exit code => 4", "1. Timeout", "2. Exit code => 3",
+		"3. Dir and Files can not be used together", "4. Timeout",
+	} {
+		if !strings.HasPrefix(list[i].Error(), want) {
+			t.Errorf("%d. expected prefix %q, got %q", i, want, list[i].Error())
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = os.Mkdir(filepath.Join(dir, "testdata"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tt := CommandInfo{Golden: "foo"}
+	fileStdout, fileStderr := tt.goldenFiles()
+	if err = os.WriteFile(fileStderr, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite
+	UpdateGolden = true
+	var listErr merrors.ListError
+	checkGolden(&listErr, 0, tt, "a\nb\n", "")
+	UpdateGolden = false
+	if err = listErr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(fileStdout); err != nil || string(data) != "a\nb\n" {
+		t.Errorf("stdout: got %q, %v", data, err)
+	}
+	if _, err = os.Stat(fileStderr); !os.IsNotExist(err) {
+		t.Errorf("stderr: expected the file to be removed, got %v", err)
+	}
+
+	// Compare
+	checkGolden(&listErr, 0, tt, "a\nb\n", "")
+	if err = listErr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(&listErr, 1, tt, "a\nc\n", "")
+	if len(listErr) != 1 {
+		t.Fatalf("expected 1 error, got %d:\n%+v", len(listErr), listErr)
+	}
+	want := "1. Stdout differs from golden file (run with -update to rewrite it)\n" +
+		"--- testdata/foo.golden\n" +
+		"+++ Stdout\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" a\n" +
+		"-b\n" +
+		"+c\n" +
+		"* * *"
+	if got := listErr[0].Error(); got != filepath.FromSlash(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if d := diff("a", "b", "x\n", "x\n"); d != "" {
+		t.Errorf("equal: got %q", d)
+	}
+
+	want := make([]string, 12)
+	got := make([]string, 12)
+	for i := range want {
+		want[i] = strconv.Itoa(i)
+		got[i] = want[i]
+	}
+	got[1] = "one"
+	got[10] = "ten"
+
+	d := diff("want", "got", strings.Join(want, "\n")+"\n", strings.Join(got, "\n"))
+	expected := "--- want\n+++ got\n" +
+		"@@ -1,5 +1,5 @@\n 0\n-1\n+one\n 2\n 3\n 4\n" +
+		"@@ -8,5 +8,5 @@\n 7\n 8\n 9\n-10\n-11\n+ten\n+11\n" +
+		"\\ No newline at end of file\n"
+	if d != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", d, expected)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{" a  b ", []string{"a", "b"}},
+		{`a "b c" 'd e'`, []string{"a", "b c", "d e"}},
+		{`"a\"b" 'a\b' a\ b ''`, []string{`a"b`, `a\b`, "a b", ""}},
+		{`x"y z"w`, []string{"xy zw"}},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.in)
+		if err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"a`, `'a`, `a\`} {
+		if _, err := SplitArgs(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tmp := filepath.Clean(os.TempDir())
+	work := filepath.Join(tmp, "cmdutil-123")
+
+	in := work + "/a.txt \n" + tmp + "/b.txt\n" +
+		tmp + "l/c.txt\n" + "/x" + tmp + "/d.txt\n" + "'" + tmp + "'\n"
+	want := "$WORK/a.txt\n$TMPDIR/b.txt\n" +
+		tmp + "l/c.txt\n" + "/x" + tmp + "/d.txt\n" + "'$TMPDIR'\n"
+	if got := (NormTempPaths | NormTrailingSpace).apply(in, work); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRunScripts(t *testing.T) {
+	RunScripts(t, "testdata")
+}
+
+func TestParseArchive(t *testing.T) {
+	data := "exec cat a\n\n-- a --\nfoo\n-- dir/b --\n-- c --\nbar\n"
+
+	comment, files := parseArchive([]byte(data))
+	if comment != "exec cat a\n\n" {
+		t.Errorf("comment: got %q", comment)
+	}
+	want := []archiveFile{{"a", "foo\n"}, {"dir/b", ""}, {"c", "bar\n"}}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	for i, f := range files {
+		if f != want[i] {
+			t.Errorf("%d. got %+v, want %+v", i, f, want[i])
+		}
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	dst := "mode: set\na/a.go:1.1,2.2 1 0\na/a.go:3.1,4.2 2 1\nb/b.go:1.1,2.2 1 0\n"
+	src := "mode: set\na/a.go:1.1,2.2 1 1\nb/b.go:1.1,2.2 1 0\nmain/main.go:1.1,2.2 1 1\n"
+	want := "mode: set\na/a.go:1.1,2.2 1 1\na/a.go:3.1,4.2 2 1\nb/b.go:1.1,2.2 1 0\n"
+
+	got, err := mergeProfiles([]byte(dst), []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("set mode: got\n%s\nwant\n%s", got, want)
+	}
+
+	dst = "mode: count\na/a.go:1.1,2.2 1 2\n"
+	src = "mode: count\na/a.go:1.1,2.2 1 3\n"
+	want = "mode: count\na/a.go:1.1,2.2 1 5\n"
+
+	if got, err = mergeProfiles([]byte(dst), []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("count mode: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build cmdutil_tag
+// +build cmdutil_tag
+
+package main
+
+func init() { tagged = true }
@@ -0,0 +1,74 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is set through the flag -ldflags.
+var version = "devel"
+
+// tagged is set by the build tag "cmdutil_tag".
+var tagged bool
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "Missing required argument: ACTION")
+		os.Exit(2)
+	}
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "echo":
+		fmt.Println(strings.Join(args, "|"))
+	case "env":
+		fmt.Println(os.Getenv(args[0]))
+	case "cat":
+		if len(args) == 0 {
+			io.Copy(os.Stdout, os.Stdin)
+			return
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+	case "exit":
+		code, _ := strconv.Atoi(args[0])
+		fmt.Fprintf(os.Stderr, "exit with %d\n", code)
+		os.Exit(code)
+	case "version":
+		fmt.Println(version, tagged)
+	case "sleep":
+		d, _ := time.ParseDuration(args[0])
+		time.Sleep(d)
+	case "spawn":
+		// A child which keeps the standard output open.
+		exe, err := os.Executable()
+		if err == nil {
+			cmd := exec.Command(exe, "sleep", args[0])
+			cmd.Stdout = os.Stdout
+			err = cmd.Start()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		time.Sleep(time.Hour)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown action %q\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -7,49 +7,240 @@
 package goutil
 
 import (
+	"context"
 	"log"
 	"os"
 	"sync"
+	"time"
 )
 
-var exitStatus = 0
-var exitMu sync.Mutex
+// An Exiter handles the exit of a program: its exit status, and the hooks to
+// be run before of exiting.
+//
+// The package-level functions use the Exiter DefaultExiter. Tests can use
+// their own Exiter, replacing the function which exits.
+// The zero value is ready to use.
+type Exiter struct {
+	// ExitFunc is called to exit with the status code; os.Exit if it is nil.
+	ExitFunc func(code int)
 
-func SetExitStatus(n int) {
-	exitMu.Lock()
-	if exitStatus < n {
-		exitStatus = n
+	// Logf logs the messages of Errorf, Fatalf and ExitWithError;
+	// the package-level Logf if it is nil.
+	Logf func(format string, args ...interface{})
+
+	// ShutdownTimeout is the maximum time to run all hooks at exit;
+	// the package-level ShutdownTimeout is used if it is 0.
+	// The hooks not run before it expires are skipped.
+	ShutdownTimeout time.Duration
+
+	mu     sync.Mutex
+	status int
+	hooks  []*Hook
+}
+
+// DefaultExiter is the Exiter used by the package-level functions.
+var DefaultExiter = new(Exiter)
+
+var (
+	// Logf is the function used to log the messages of Errorf, Fatalf and
+	// ExitWithError, by the Exiters without their own Logf.
+	Logf = log.Printf
+
+	// ShutdownTimeout is the maximum time to run all hooks at exit, by the
+	// Exiters without their own ShutdownTimeout; 0 means no limit.
+	ShutdownTimeout time.Duration
+)
+
+func (e *Exiter) exit(code int) {
+	if e.ExitFunc != nil {
+		e.ExitFunc(code)
+		return
+	}
+	os.Exit(code)
+}
+
+func (e *Exiter) logf(format string, args ...interface{}) {
+	if e.Logf != nil {
+		e.Logf(format, args...)
+		return
+	}
+	Logf(format, args...)
+}
+
+// SetExitStatus sets the exit status to n, if it is greater than the current one.
+func (e *Exiter) SetExitStatus(n int) {
+	e.mu.Lock()
+	if e.status < n {
+		e.status = n
 	}
-	exitMu.Unlock()
+	e.mu.Unlock()
+}
+
+// ExitStatus returns the exit status.
+func (e *Exiter) ExitStatus() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Reset sets the exit status to 0 and unregisters all hooks.
+// It is meant to be used in tests.
+func (e *Exiter) Reset() {
+	e.mu.Lock()
+	e.status = 0
+	e.hooks = nil
+	e.mu.Unlock()
 }
 
-var atexitFuncs []func()
+// A Hook is a function registered to be run at exit.
+type Hook struct {
+	e       *Exiter
+	f       func(ctx context.Context)
+	timeout time.Duration
+}
+
+// AtExit registers the function f to be run by Exit.
+func (e *Exiter) AtExit(f func()) *Hook {
+	return e.AtExitContext(func(context.Context) { f() }, 0)
+}
 
-func AtExit(f func()) {
-	atexitFuncs = append(atexitFuncs, f)
+// AtExitContext registers the function f to be run by Exit.
+// The context passed to f is cancelled when the timeout expires, if it is
+// not 0, or when the ShutdownTimeout expires.
+//
+// The hooks are run in reverse order of registration, like deferred calls.
+func (e *Exiter) AtExitContext(f func(ctx context.Context), timeout time.Duration) *Hook {
+	h := &Hook{e: e, f: f, timeout: timeout}
+
+	e.mu.Lock()
+	e.hooks = append(e.hooks, h)
+	e.mu.Unlock()
+	return h
 }
 
-func Exit() {
-	for _, f := range atexitFuncs {
-		f()
+// Remove unregisters the hook so it is not run at exit.
+// It reports whether the hook was registered.
+func (h *Hook) Remove() bool {
+	h.e.mu.Lock()
+	defer h.e.mu.Unlock()
+
+	for i, v := range h.e.hooks {
+		if v == h {
+			h.e.hooks = append(h.e.hooks[:i], h.e.hooks[i+1:]...)
+			return true
+		}
 	}
-	os.Exit(exitStatus)
+	return false
 }
 
-func Fatalf(format string, args ...interface{}) {
-	log.Printf(format, args...)
-	Exit()
+// run runs the hook, waiting until it returns or its context is done.
+// A panic in the hook is recovered and reported through Errorf.
+func (h *Hook) run(ctx context.Context) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				h.e.Errorf("panic at exit: %v", r)
+			}
+		}()
+		h.f(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		h.e.Errorf("hook at exit: %v", ctx.Err())
+	}
+}
+
+// runHooks runs the registered hooks, in reverse order.
+// The hooks are unregistered, so a call to Exit from a hook does not run
+// them again.
+func (e *Exiter) runHooks() {
+	e.mu.Lock()
+	hooks := e.hooks
+	e.hooks = nil
+	e.mu.Unlock()
+
+	timeout := e.ShutdownTimeout
+	if timeout == 0 {
+		timeout = ShutdownTimeout
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			e.Errorf("hooks at exit: %v; %d not run", ctx.Err(), i+1)
+			return
+		}
+		hooks[i].run(ctx)
+	}
 }
 
-func Errorf(format string, args ...interface{}) {
-	log.Printf(format, args...)
-	SetExitStatus(1)
+// Exit runs the hooks registered by AtExit, and then it exits with the exit
+// status set.
+func (e *Exiter) Exit() {
+	e.runHooks()
+	e.exit(e.ExitStatus())
 }
 
-//var logf = log.Printf
+// Fatalf logs the message and exits through Exit.
+func (e *Exiter) Fatalf(format string, args ...interface{}) {
+	e.logf(format, args...)
+	e.Exit()
+}
 
-func ExitIfErrors() {
-	if exitStatus != 0 {
-		Exit()
+// Errorf logs the message and sets the exit status to ExitFailure.
+func (e *Exiter) Errorf(format string, args ...interface{}) {
+	e.logf(format, args...)
+	e.SetExitStatus(ExitFailure)
+}
+
+// ExitIfErrors exits through Exit if the exit status is not 0.
+func (e *Exiter) ExitIfErrors() {
+	if e.ExitStatus() != 0 {
+		e.Exit()
 	}
 }
+
+// * * *
+
+// SetExitStatus sets the exit status to n, if it is greater than the current one.
+func SetExitStatus(n int) { DefaultExiter.SetExitStatus(n) }
+
+// AtExit registers the function f to be run by Exit.
+func AtExit(f func()) *Hook { return DefaultExiter.AtExit(f) }
+
+// AtExitContext registers the function f to be run by Exit.
+// See (*Exiter).AtExitContext.
+func AtExitContext(f func(ctx context.Context), timeout time.Duration) *Hook {
+	return DefaultExiter.AtExitContext(f, timeout)
+}
+
+// Exit runs the hooks registered by AtExit, and then it exits with the exit
+// status set.
+func Exit() { DefaultExiter.Exit() }
+
+// Fatalf logs the message and exits through Exit.
+func Fatalf(format string, args ...interface{}) { DefaultExiter.Fatalf(format, args...) }
+
+// Errorf logs the message and sets the exit status to ExitFailure.
+func Errorf(format string, args ...interface{}) { DefaultExiter.Errorf(format, args...) }
+
+// ExitIfErrors exits through Exit if the exit status is not 0.
+func ExitIfErrors() { DefaultExiter.ExitIfErrors() }
+
+// Reset resets the DefaultExiter. It is meant to be used in tests.
+func Reset() { DefaultExiter.Reset() }
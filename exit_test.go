@@ -0,0 +1,138 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package goutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tredoe/goutil/merrors"
+)
+
+func TestAtExit(t *testing.T) {
+	var mu sync.Mutex
+	var order []int
+	add := func(n int) {
+		mu.Lock()
+		order = append(order, n)
+		mu.Unlock()
+	}
+
+	var msgs []string
+	code := -1
+	e := &Exiter{
+		ExitFunc: func(n int) { code = n },
+		Logf: func(format string, args ...interface{}) {
+			mu.Lock()
+			msgs = append(msgs, fmt.Sprintf(format, args...))
+			mu.Unlock()
+		},
+	}
+
+	e.AtExit(func() { add(1) })
+	h := e.AtExit(func() { add(2) })
+	e.AtExit(func() { panic("boom") })
+	e.AtExitContext(func(ctx context.Context) {
+		add(3)
+		time.Sleep(time.Hour)
+	}, 10*time.Millisecond)
+
+	if !h.Remove() {
+		t.Error("expected the hook to be removed")
+	}
+	if h.Remove() {
+		t.Error("expected the hook to be already removed")
+	}
+
+	e.Exit()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []int{3, 1}; !reflect.DeepEqual(order, want) {
+		t.Errorf("got %v, want %v", order, want)
+	}
+	if want := []string{
+		"hook at exit: context deadline exceeded",
+		"panic at exit: boom",
+	}; !reflect.DeepEqual(msgs, want) {
+		t.Errorf("got %q, want %q", msgs, want)
+	}
+	if code != ExitFailure {
+		t.Errorf("expected exit status %d, got %d", ExitFailure, code)
+	}
+
+	e.Reset()
+	if e.ExitStatus() != 0 {
+		t.Error("expected exit status 0 after Reset")
+	}
+}
+
+func TestExiterDefaults(t *testing.T) {
+	var msgs []string
+	defer func(logf func(string, ...interface{}), timeout time.Duration) {
+		Logf, ShutdownTimeout = logf, timeout
+	}(Logf, ShutdownTimeout)
+
+	Logf = func(format string, args ...interface{}) {
+		msgs = append(msgs, fmt.Sprintf(format, args...))
+	}
+	ShutdownTimeout = 10 * time.Millisecond
+
+	e := &Exiter{ExitFunc: func(int) {}}
+	e.AtExit(func() {})
+	e.AtExitContext(func(ctx context.Context) { <-ctx.Done() }, 0)
+	e.Exit()
+
+	if want := []string{
+		"hook at exit: context deadline exceeded",
+		"hooks at exit: context deadline exceeded; 1 not run",
+	}; !reflect.DeepEqual(msgs, want) {
+		t.Errorf("got %q, want %q", msgs, want)
+	}
+}
+
+func TestExitWithError(t *testing.T) {
+	code := -1
+	e := &Exiter{
+		ExitFunc: func(n int) { code = n },
+		Logf:     func(string, ...interface{}) {},
+	}
+
+	e.ExitWithError(WithExitCode(errors.New("foo"), ExitUsage))
+	if code != ExitUsage {
+		t.Errorf("got %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	var list merrors.ListError
+	list.Add("foo")
+	list.AddError(WithExitCode(errors.New("bar"), ExitDataErr))
+	list.AddError(fmt.Errorf("baz: %w", WithExitCode(errors.New("qux"), ExitConfig)))
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{errors.New("foo"), ExitFailure},
+		{WithExitCode(errors.New("foo"), ExitUsage), ExitUsage},
+		{fmt.Errorf("foo: %w", WithExitCode(errors.New("bar"), ExitNoPerm)), ExitNoPerm},
+		{list, ExitConfig},
+		{errors.Join(errors.New("foo"), list), ExitConfig},
+	}
+	for i, tt := range tests {
+		if got := ExitCodeOf(tt.err); got != tt.want {
+			t.Errorf("%d. got %d, want %d", i, got, tt.want)
+		}
+	}
+}
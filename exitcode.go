@@ -0,0 +1,104 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package goutil
+
+// Exit status codes, based in the ones of 'sysexits.h' from BSD.
+const (
+	ExitOK          = 0  // successful termination
+	ExitFailure     = 1  // generic failure
+	ExitUsage       = 64 // command line usage error
+	ExitDataErr     = 65 // data format error
+	ExitNoInput     = 66 // cannot open input
+	ExitNoUser      = 67 // addressee unknown
+	ExitNoHost      = 68 // host name unknown
+	ExitUnavailable = 69 // service unavailable
+	ExitSoftware    = 70 // internal software error
+	ExitOSErr       = 71 // system error (e.g., can't fork)
+	ExitOSFile      = 72 // critical OS file missing
+	ExitCantCreate  = 73 // can't create (user) output file
+	ExitIOErr       = 74 // input/output error
+	ExitTempFail    = 75 // temporary failure; user is invited to retry
+	ExitProtocol    = 76 // remote error in protocol
+	ExitNoPerm      = 77 // permission denied
+	ExitConfig      = 78 // configuration error
+)
+
+// ExitCoder is implemented by the errors which carry their exit status.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// exitError is an error with an exit status.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+func (e *exitError) ExitCode() int { return e.code }
+
+// WithExitCode returns an error which wraps err, with the exit status code.
+// It returns nil if err is nil.
+func WithExitCode(err error, code int) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{err, code}
+}
+
+// ExitCodeOf returns the exit status for the error err: 0 if it is nil;
+// the code of the first ExitCoder found in its chain; or ExitFailure.
+//
+// For an error which wraps multiple errors, like the ones of package
+// "github.com/tredoe/goutil/merrors" or the built by errors.Join, it is the
+// greatest code of the errors wrapped.
+func ExitCodeOf(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if code, ok := exitCode(err); ok {
+		return code
+	}
+	return ExitFailure
+}
+
+// exitCode returns the exit status carried by err, if any.
+func exitCode(err error) (int, bool) {
+	switch v := err.(type) {
+	case nil:
+		return 0, false
+	case ExitCoder:
+		return v.ExitCode(), true
+	case interface{ Unwrap() []error }:
+		max, found := 0, false
+		for _, err := range v.Unwrap() {
+			if code, ok := exitCode(err); ok && (!found || code > max) {
+				max, found = code, true
+			}
+		}
+		return max, found
+	case interface{ Unwrap() error }:
+		return exitCode(v.Unwrap())
+	}
+	return 0, false
+}
+
+// ExitWithError logs the error err, if any, and then it exits through Exit
+// with the exit status given by ExitCodeOf.
+// The status set before by SetExitStatus is kept if it is greater.
+func ExitWithError(err error) { DefaultExiter.ExitWithError(err) }
+
+// ExitWithError logs the error err, like the package-level function.
+func (e *Exiter) ExitWithError(err error) {
+	if err != nil {
+		e.logf("%+v", err)
+	}
+	e.SetExitStatus(ExitCodeOf(err))
+	e.Exit()
+}
@@ -13,17 +13,31 @@ package flagplus
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"reflect"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/tredoe/goutil"
 )
 
+// exitUsage is the exit status for the errors in the command line, like in
+// package "flag".
+const exitUsage = 2
+
+// exitInterrupt is the exit status when the program is interrupted by SIGINT:
+// 128 + the signal number, like in shells.
+const exitInterrupt = 130
+
 // A Subcommand is an implementation of a sub-command.
 // Every command gets an extra flag, help, which shows its documentation.
 type Subcommand struct {
@@ -31,6 +45,12 @@ type Subcommand struct {
 	// The args are the arguments after the command name.
 	Run func(cmd *Subcommand, args []string)
 
+	// RunE runs the command, returning an error instead of exiting; it is
+	// used instead of Run if it is not nil.
+	// The context is the one passed to (*Command).Execute, and it is
+	// cancelled when the program receives an interrupt signal.
+	RunE func(ctx context.Context, cmd *Subcommand, args []string) error
+
 	// UsageLine is the one-line usage message.
 	// The first word in the line is taken to be the command name.
 	UsageLine string
@@ -42,37 +62,35 @@ type Subcommand struct {
 	Long string
 
 	// Flag is a set of flags specific for this command; it is set using method
-	// (*Subcommand).AddFlags
+	// (*Subcommand).AddFlags, or defining the flags directly in it.
 	FlagSet flag.FlagSet
 
 	// CustomFlags indicates that the command will do its own flag parsing.
 	CustomFlags bool
+
+	// Subcommands are the sub-commands of this command, like "add" in
+	// "<program> remote add". A command with sub-commands can have no Run.
+	Subcommands []*Subcommand
+
+	parent    *Subcommand
+	cmd       *Command
+	flagNames []string // added through AddFlags
 }
 
-// AddFlags looks up the flags in the global flag.FlagSet and they are added
-// to the sub-command, if they are found.
-func (s *Subcommand) AddFlags(names ...string) *Subcommand {
-	invalidNames := make([]string, 0)
-	hasError := false
-
-	for _, v := range names {
-		_flag := flag.Lookup(v)
-		if _flag == nil {
-			invalidNames = append(invalidNames, v)
-			hasError = true
-		} else if !hasError {
-			s.FlagSet.Var(_flag.Value, _flag.Name, _flag.Usage)
-		}
+// AddSubcommands adds sub-commands to the command.
+func (s *Subcommand) AddSubcommands(cmds ...*Subcommand) *Subcommand {
+	for _, v := range cmds {
+		v.parent = s
 	}
+	s.Subcommands = append(s.Subcommands, cmds...)
+	return s
+}
 
-	if hasError {
-		errMsg := "flag does not exist"
-		if len(invalidNames) != 1 {
-			errMsg = "flags do not exist"
-		}
-		fmt.Fprintf(os.Stderr, "%s: %s\n", errMsg, strings.Join(invalidNames, ", "))
-		os.Exit(2)
-	}
+// AddFlags adds flags to the sub-command. The flags are looked up by name when
+// the command line is parsed: at first, in the flag set of the Command, and
+// then in the global flag.FlagSet; it is an error if any is not found.
+func (s *Subcommand) AddFlags(names ...string) *Subcommand {
+	s.flagNames = append(s.flagNames, names...)
 	return s
 }
 
@@ -86,15 +104,68 @@ func (s *Subcommand) Name() string {
 	return name
 }
 
+// CommandPath returns the names of the command and its parents, separated by
+// spaces, like "remote add".
+func (s *Subcommand) CommandPath() string {
+	if s.parent == nil {
+		return s.Name()
+	}
+	return s.parent.CommandPath() + " " + s.Name()
+}
+
+// FullUsageLine returns the usage line prefixed by the names of the parents,
+// like "remote add NAME URL".
+func (s *Subcommand) FullUsageLine() string {
+	if s.parent == nil {
+		return s.UsageLine
+	}
+	return s.parent.CommandPath() + " " + s.UsageLine
+}
+
+// Usage prints the usage line to the standard error of the Command, and exits
+// with status 2.
 func (s *Subcommand) Usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s %s\n\n", os.Args[0], s.UsageLine)
-	os.Exit(2)
+	s.printUsage()
+	os.Exit(exitUsage)
+}
+
+func (s *Subcommand) printUsage() {
+	fmt.Fprintf(s.cmd.stderr(), "Usage: %s %s\n\n", s.cmd.program(), s.FullUsageLine())
 }
 
 // Runnable reports whether the command can be run; otherwise
-// it is a documentation pseudo-command such as importpath.
+// it is a documentation pseudo-command such as importpath, or a command which
+// only groups sub-commands.
 func (s *Subcommand) Runnable() bool {
-	return s.Run != nil
+	return s.Run != nil || s.RunE != nil
+}
+
+// IsTopic reports whether the command is a documentation pseudo-command: it
+// can not be run, and it has no sub-commands.
+func (s *Subcommand) IsTopic() bool {
+	return !s.Runnable() && len(s.Subcommands) == 0
+}
+
+// lookup returns the command in cmds with the given name, which is not a
+// topic; nil if it is not found.
+func lookup(cmds []*Subcommand, name string) *Subcommand {
+	for _, v := range cmds {
+		if v.Name() == name && !v.IsTopic() {
+			return v
+		}
+	}
+	return nil
+}
+
+// commandTree returns the commands in cmds and their sub-commands, at any
+// depth, in depth-first order.
+func commandTree(cmds []*Subcommand) []*Subcommand {
+	var tree []*Subcommand
+	for _, v := range cmds {
+		tree = append(tree, v)
+		tree = append(tree, commandTree(v.Subcommands)...)
+	}
+	return tree
 }
 
 // * * *
@@ -107,6 +178,16 @@ type Command struct {
 	Subcommands   []*Subcommand
 	globalFlags   []string
 	ErrorHandling flag.ErrorHandling
+
+	// Name is the program name shown in the help, and in the messages;
+	// os.Args[0] if it is empty.
+	Name string
+
+	// Stdout is where the help is printed, and Stderr is where the usage and
+	// the errors are printed; os.Stdout and os.Stderr if they are nil.
+	Stdout, Stderr io.Writer
+
+	flags *flag.FlagSet
 }
 
 // NewCommand creates a new command with a default ErrorHandling to
@@ -119,30 +200,102 @@ func NewCommand(description string, cmds ...*Subcommand) *Command {
 	}
 }
 
-// AddGlobalFlags sets the global flags which will be parsed using
-// "*Command.Parse()".
+// AddGlobalFlags sets the global flags, which are parsed before the
+// sub-command, and added to every sub-command.
+// The flags are looked up by name when the command line is parsed: at first,
+// in the flag set returned by Flags, and then in the global flag.FlagSet; it
+// is an error if any is not found.
 func (c *Command) AddGlobalFlags(names ...string) *Command {
-	invalidNames := make([]string, 0)
-	hasError := false
+	c.globalFlags = names
+	return c
+}
 
-	for _, v := range names {
-		if flag.Lookup(v) == nil {
-			invalidNames = append(invalidNames, v)
-			hasError = true
+// Flags returns the flag set of the command, used by ParseArgs to parse the
+// flags before the sub-command. The flags defined in it can be added to the
+// sub-commands through AddGlobalFlags and (*Subcommand).AddFlags.
+func (c *Command) Flags() *flag.FlagSet {
+	if c.flags == nil {
+		c.flags = flag.NewFlagSet(c.program(), flag.ContinueOnError)
+	}
+	return c.flags
+}
+
+// program returns the program name.
+func (c *Command) program() string {
+	if c == nil || c.Name == "" {
+		return os.Args[0]
+	}
+	return c.Name
+}
+
+func (c *Command) stdout() io.Writer {
+	if c == nil || c.Stdout == nil {
+		return os.Stdout
+	}
+	return c.Stdout
+}
+
+func (c *Command) stderr() io.Writer {
+	if c == nil || c.Stderr == nil {
+		return os.Stderr
+	}
+	return c.Stderr
+}
+
+// lookupFlag returns the flag with the given name, from the flag set of the
+// command, or from the global one; nil if it is not found.
+func (c *Command) lookupFlag(name string) *flag.Flag {
+	if c.flags != nil {
+		if f := c.flags.Lookup(name); f != nil {
+			return f
+		}
+	}
+	return flag.Lookup(name)
+}
+
+// setup links the sub-commands, at any depth, to their parents and to c, and
+// adds the flags to the sub-commands and to the flag set of c.
+func (c *Command) setup() error {
+	var missing []string
+
+	for _, v := range c.globalFlags {
+		f := c.lookupFlag(v)
+		if f == nil {
+			missing = append(missing, v)
+		} else if c.Flags().Lookup(v) == nil {
+			c.Flags().Var(f.Value, f.Name, f.Usage)
 		}
 	}
+	c.setupSubcommands(nil, c.Subcommands, &missing)
 
-	if hasError {
+	if len(missing) != 0 {
 		errMsg := "flag does not exist"
-		if len(invalidNames) != 1 {
+		if len(missing) != 1 {
 			errMsg = "flags do not exist"
 		}
-		fmt.Fprintf(os.Stderr, "%s: %s\n", errMsg, strings.Join(invalidNames, ", "))
-		os.Exit(2)
+		return goutil.WithExitCode(
+			fmt.Errorf("%s: %s\n", errMsg, strings.Join(missing, ", ")), exitUsage)
 	}
+	return nil
+}
 
-	c.globalFlags = names
-	return c
+func (c *Command) setupSubcommands(parent *Subcommand, cmds []*Subcommand, missing *[]string) {
+	for _, s := range cmds {
+		s.parent = parent
+		s.cmd = c
+
+		for _, name := range s.flagNames {
+			if s.FlagSet.Lookup(name) != nil {
+				continue
+			}
+			if f := c.lookupFlag(name); f != nil {
+				s.FlagSet.Var(f.Value, f.Name, f.Usage)
+			} else {
+				*missing = append(*missing, name)
+			}
+		}
+		c.setupSubcommands(s, s.Subcommands, missing)
+	}
 }
 
 // HasGlobalFlags tests whether the command has global flags.
@@ -158,82 +311,242 @@ func (c *Command) GetGlobalFlags() []string { return c.globalFlags }
 
 // Parse parses both command and flag definitions from the argument list.
 // Also, the global flags are added to each sub-command, if any.
+// The flags before the sub-command are parsed through the global
+// flag.FlagSet; see ParseArgs to not use it.
+//
+// An error is handled according to ErrorHandling; see Execute.
 func (c *Command) Parse() {
-	var err error
+	err := c.setup()
+	if err == nil {
+		flag.Usage = c.Usage
+		flag.Parse()
+		err = c.parse(context.Background(), flag.Args(), false)
+	}
+	c.handleError(err)
+}
+
+// ParseArgs parses the arguments args, without the program name, and runs the
+// sub-command like Parse. But the flags before the sub-command are parsed
+// through the flag set returned by Flags, and an error is returned, instead
+// of being printed and handled according to ErrorHandling.
+//
+// It does not use the global flag.FlagSet to parse, so multiple commands can
+// be run, like in tests, in the same process. But the global flags which are
+// not defined in Flags are looked up in flag.CommandLine, and their values
+// are shared, so they are set by every command which parses them.
+func (c *Command) ParseArgs(args []string) error {
+	return c.ExecuteArgs(context.Background(), args)
+}
 
-	flag.Usage = c.Usage
-	flag.Parse()
+// ExecuteArgs is like ParseArgs, but it runs the sub-command with the context
+// ctx, like Execute. The signals are not handled; to cancel the sub-command,
+// cancel ctx.
+func (c *Command) ExecuteArgs(ctx context.Context, args []string) error {
+	fs := c.Flags()
+	fs.SetOutput(c.stderr())
+	fs.Usage = func() { c.printUsage(c.stderr()) }
+
+	if err := c.setup(); err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return flagError(err)
+	}
+	return c.parse(ctx, fs.Args(), false)
+}
 
-	args := flag.Args()
+// Execute parses the command line like Parse, and runs the sub-command with
+// the context ctx. It returns the error of the sub-command, if it was set
+// through RunE, or the error found in the command line.
+//
+// While a sub-command set through RunE is running, the first interrupt signal
+// (SIGINT) cancels its context, and the next one gets the default handling,
+// which exits the program. The signals are not handled for the sub-commands
+// set through Run.
+//
+// The error is printed to standard error and, according to ErrorHandling:
+// returned by ContinueOnError; passed to panic by PanicOnError; or, by
+// ExitOnError, the program exits with the status 2 for the errors in the
+// command line, 130 if it was interrupted, or else the one given by
+// goutil.ExitCodeOf.
+func (c *Command) Execute(ctx context.Context) error {
+	err := c.setup()
+	if err == nil {
+		flag.Usage = c.Usage
+		flag.Parse()
+		err = c.parse(ctx, flag.Args(), true)
+	}
+	return c.handleError(err)
+}
+
+// parse parses the arguments after the global flags, and runs the sub-command
+// with ctx, handling the interrupt signal if interrupt is true.
+// The errors in the command line have the exit status exitUsage, and their
+// messages end with a new line.
+func (c *Command) parse(ctx context.Context, args []string, interrupt bool) error {
 	if len(args) < 1 {
-		err = fmt.Errorf("%s\n", c.Description)
-	} else if args[0] == "help" {
-		if err = c.help(args[1:]); err == nil {
-			return
-		}
-	} else {
-		hasGlobalFlags := false
-		if len(c.globalFlags) != 0 {
-			hasGlobalFlags = true
-		}
+		return goutil.WithExitCode(fmt.Errorf("%s\n", c.Description), exitUsage)
+	}
+	if args[0] == "help" {
+		return goutil.WithExitCode(c.help(args[1:]), exitUsage)
+	}
+	return c.run(ctx, args, interrupt)
+}
 
-		for _, subc := range c.Subcommands {
-			if subc.Name() == args[0] && subc.Run != nil {
-				subc.FlagSet.Usage = func() { subc.Usage() }
+// flagErr is an error returned by a flag.FlagSet, which has already printed it.
+type flagErr struct{ err error }
 
-				if hasGlobalFlags {
-					for _, v := range c.globalFlags {
-						_flag := flag.Lookup(v)
-						subc.FlagSet.Var(_flag.Value, _flag.Name, _flag.Usage)
-					}
-				}
+func (e *flagErr) Error() string { return e.err.Error() }
+func (e *flagErr) Unwrap() error { return e.err }
 
-				if subc.CustomFlags {
-					args = args[1:]
-				} else {
-					subc.FlagSet.Parse(args[1:])
-					args = subc.FlagSet.Args()
-				}
+// flagError returns the error err of a flag.FlagSet, with the exit status 0
+// if the help was requested, or exitUsage.
+func flagError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return goutil.WithExitCode(&flagErr{err}, 0)
+	}
+	return goutil.WithExitCode(&flagErr{err}, exitUsage)
+}
 
-				subc.Run(subc, args)
-				return
-			}
+// handleError prints the error err, if any, and handles it according to
+// ErrorHandling.
+func (c *Command) handleError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var fErr *flagErr
+	if !errors.As(err, &fErr) {
+		msg := err.Error()
+		if !strings.HasSuffix(msg, "\n") {
+			msg = fmt.Sprintf("%s: %s\n", c.program(), msg)
 		}
-		err = fmt.Errorf("Unknown subcommand %q.  Run `%s help` for usage.\n",
-			args[0], os.Args[0])
+		fmt.Fprint(c.stderr(), msg)
 	}
 
 	switch c.ErrorHandling {
-	case flag.ContinueOnError:
-		fmt.Fprint(os.Stderr, err)
 	case flag.ExitOnError:
-		fmt.Fprint(os.Stderr, err)
-		os.Exit(2)
+		os.Exit(goutil.ExitCodeOf(err))
 	case flag.PanicOnError:
 		panic(err)
 	}
+	return err
+}
+
+// run dispatches the arguments to the sub-command at any depth, like
+// "remote add" in "<program> remote add -v origin URL", and runs it.
+// The global flags are added to every sub-command in the path.
+// If interrupt is true, a sub-command set through RunE is run by
+// runInterruptible.
+func (c *Command) run(ctx context.Context, args []string, interrupt bool) error {
+	var subc *Subcommand
+	cmds := c.Subcommands
+
+	for len(args) != 0 {
+		next := lookup(cmds, args[0])
+		if next == nil {
+			break
+		}
+		subc = next
+		subc.FlagSet.Usage = next.printUsage
+		subc.FlagSet.SetOutput(c.stderr())
+
+		for _, v := range c.globalFlags {
+			if subc.FlagSet.Lookup(v) != nil {
+				continue
+			}
+			_flag := c.lookupFlag(v)
+			subc.FlagSet.Var(_flag.Value, _flag.Name, _flag.Usage)
+		}
+
+		args = args[1:]
+		if !subc.CustomFlags {
+			if err := subc.FlagSet.Parse(args); err != nil {
+				return flagError(err)
+			}
+			args = subc.FlagSet.Args()
+		}
+		cmds = subc.Subcommands
+	}
+
+	var err error
+	switch {
+	case subc == nil:
+		err = fmt.Errorf("Unknown subcommand %q.  Run `%s help` for usage.\n",
+			args[0], c.program())
+	case !subc.Runnable() && len(args) == 0:
+		err = fmt.Errorf("Missing subcommand for %q.  Run `%s help %s` for usage.\n",
+			subc.CommandPath(), c.program(), subc.CommandPath())
+	case !subc.Runnable():
+		err = fmt.Errorf("Unknown subcommand %q.  Run `%s help %s` for usage.\n",
+			subc.CommandPath()+" "+args[0], c.program(), subc.CommandPath())
+	}
+	if err != nil {
+		return goutil.WithExitCode(err, exitUsage)
+	}
+
+	switch {
+	case subc.RunE != nil && interrupt:
+		return runInterruptible(ctx, subc, args)
+	case subc.RunE != nil:
+		return subc.RunE(ctx, subc, args)
+	}
+	subc.Run(subc, args)
+	return nil
+}
+
+// runInterruptible runs the sub-command with a context which is cancelled at
+// the first interrupt signal. The handling of the signal is stopped before
+// cancelling the context, so the next signal exits the program.
+// The error returned after an interrupt has the exit status exitInterrupt.
+func runInterruptible(ctx context.Context, subc *Subcommand, args []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	interrupted := make(chan struct{})
+	go func() {
+		select {
+		case <-sig:
+			signal.Stop(sig)
+			close(interrupted)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := subc.RunE(ctx, subc, args)
+	if err != nil {
+		select {
+		case <-interrupted:
+			err = goutil.WithExitCode(err, exitInterrupt)
+		default:
+		}
+	}
+	return err
 }
 
+// Usage prints the usage to the standard error of c, and exits with status 2.
 func (c *Command) Usage() {
-	c.printUsage(os.Stderr)
-	os.Exit(2)
+	c.printUsage(c.stderr())
+	os.Exit(exitUsage)
 }
 
 // help implements the "help" command.
 // "help documentation" generates documentation of all commands in 'doc.go'.
+// The help of a sub-command at any depth is shown through its path, like in
+// "help remote add".
 func (c *Command) help(args []string) error {
 	if len(args) == 0 { // Succeeded at "<program> help".
-		c.printUsage(os.Stdout)
+		c.printUsage(c.stdout())
 		return nil
 	}
-	if len(args) != 1 { // Failed at "<program> help".
-		return fmt.Errorf("Usage: %s help command\n\nToo many arguments given.\n", os.Args[0])
-	}
-
-	arg := args[0]
 
 	// "<program> help documentation" generates 'doc.go'.
-	if arg == "documentation" {
+	if len(args) == 1 && args[0] == "documentation" {
 		buf := new(bytes.Buffer)
 		c.printUsage(buf)
 		usage := &Subcommand{Long: buf.String()}
@@ -242,21 +555,35 @@ func (c *Command) help(args []string) error {
 		if err != nil {
 			return err
 		}
-		tmpl(file, documentationTemplate, append([]*Subcommand{usage}, c.Subcommands...))
+		c.tmpl(file, documentationTemplate, append([]*Subcommand{usage}, commandTree(c.Subcommands)...))
 		return file.Close()
 	}
 
-	for _, subc := range c.Subcommands {
-		if subc.Name() == arg { // Succeeded at "<program> help <cmd>".
-			tmpl(os.Stdout, helpTemplate, subc)
-			return nil
+	// "<program> help <cmd> <sub-cmd>..."
+	var subc *Subcommand
+	cmds := c.Subcommands
+
+	for i, arg := range args {
+		subc = nil
+		for _, v := range cmds {
+			if v.Name() == arg {
+				subc = v
+				break
+			}
 		}
+		if subc == nil { // Failed at "<program> help <cmd>"
+			return fmt.Errorf("Unknown help topic %q.  Run `%s help` for usage.\n",
+				strings.Join(args[:i+1], " "), c.program())
+		}
+		cmds = subc.Subcommands
 	}
-	// Failed at "<program> help <cmd>"
-	return fmt.Errorf("Unknown help topic %q.  Run `%s help` for usage.\n", arg, os.Args[0])
+
+	// Succeeded at "<program> help <cmd>".
+	c.tmpl(c.stdout(), helpTemplate, subc)
+	return nil
 }
 
-func (c *Command) printUsage(w io.Writer) { tmpl(w, usageTemplate, c) }
+func (c *Command) printUsage(w io.Writer) { c.tmpl(w, usageTemplate, c) }
 
 // == Templates
 //
@@ -267,24 +594,30 @@ Usage:
       {{program}}{{if .HasGlobalFlags}} [global flags]{{end}} command [flags] [arguments]
 
 ## Commands
-{{range .Subcommands}}{{if .Runnable}}
-    {{.Name | printf "%-11s"}} {{.Short}}{{end}}{{end}}
+{{$width := pathWidth .Subcommands}}{{range tree .Subcommands}}{{if not .IsTopic}}
+    {{.CommandPath | pad $width}} {{.Short}}{{end}}{{end}}
 
 Use "{{program}} help [command]" for more information about a command.
 {{if hasExtraTopic .Subcommands}}
 Additional help topics:
-{{range .Subcommands}}{{if not .Runnable}}
-    {{.Name | printf "%-11s"}} {{.Short}}{{end}}{{end}}
+{{range tree .Subcommands}}{{if .IsTopic}}
+    {{.CommandPath | pad $width}} {{.Short}}{{end}}{{end}}
 
 Use "{{program}} help [topic]" for more information about that topic.
 {{end}}
 {{if .HasGlobalFlags}}## Global flags
 {{printGlobFlags .GetGlobalFlags}}{{end}}`
 
-var helpTemplate = `{{if .Runnable}}Usage: {{program}} {{.UsageLine}}
+var helpTemplate = `{{if not .IsTopic}}Usage: {{program}} {{.FullUsageLine}}
 
 {{end}}{{.Long | trim}}
-{{if hasFlags .FlagSet}}
+{{if .Subcommands}}
+Commands:
+{{$width := pathWidth .Subcommands}}{{range .Subcommands}}
+    {{.CommandPath | pad $width}} {{.Short}}{{end}}
+
+Use "{{program}} help {{.CommandPath}} [command]" for more information about a command.
+{{end}}{{if hasFlags .FlagSet}}
 Flags:
 {{printDefaults .FlagSet}}{{end}}
 `
@@ -297,7 +630,7 @@ var documentationTemplate = `// DO NOT EDIT THIS FILE. GENERATED BY "{{cmdLine}}
 
 {{.Short | capitalize}}
 
-{{end}}{{if .Runnable}}Usage: {{program}} {{.UsageLine}}
+{{end}}{{if not .IsTopic}}Usage: {{program}} {{.FullUsageLine}}
 
 {{end}}{{.Long | trim}}
 
@@ -306,16 +639,19 @@ package main
 `
 
 // tmpl executes the given template text on data, writing the result to w.
-func tmpl(w io.Writer, text string, data interface{}) {
+func (c *Command) tmpl(w io.Writer, text string, data interface{}) {
 	t := template.New("top")
 	t.Funcs(template.FuncMap{
 		"capitalize":    capitalize,
 		"hasExtraTopic": hasExtraTopic,
 		"hasFlags":      hasFlags,
+		"pad":           pad,
+		"pathWidth":     pathWidth,
+		"tree":          commandTree,
 		"trim":          strings.TrimSpace,
 
-		"cmdLine": func() string { return strings.Join(os.Args, " ") },
-		"program": func() string { return os.Args[0] },
+		"cmdLine": func() string { return c.program() + " help documentation" },
+		"program": c.program,
 
 		"printDefaults": func(f flag.FlagSet) string {
 			f.SetOutput(w)
@@ -323,16 +659,14 @@ func tmpl(w io.Writer, text string, data interface{}) {
 			return ""
 		},
 		"printGlobFlags": func(names []string) string {
-			flag.VisitAll(func(f *flag.Flag) {
-				found := false
-				for _, v := range names {
-					if v == f.Name {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return
+			sorted := make([]string, len(names))
+			copy(sorted, names)
+			sort.Strings(sorted)
+
+			for _, name := range sorted {
+				f := c.lookupFlag(name)
+				if f == nil {
+					continue
 				}
 
 				format := ""
@@ -348,7 +682,7 @@ func tmpl(w io.Writer, text string, data interface{}) {
 				}
 
 				fmt.Fprintf(w, format, f.Name, f.DefValue, f.Usage)
-			})
+			}
 
 			fmt.Fprint(w, "\n\n")
 			return ""
@@ -368,9 +702,27 @@ func capitalize(s string) string {
 	return string(unicode.ToTitle(r)) + s[n:]
 }
 
+// pad returns s padded with spaces on the right until the given width.
+func pad(width int, s string) string {
+	return fmt.Sprintf("%-*s", width, s)
+}
+
+// pathWidth returns the width of the column of command paths for the commands
+// in cmds and their sub-commands: 11 characters, or more if any path does not
+// fit with a space after it.
+func pathWidth(cmds []*Subcommand) int {
+	width := 11
+	for _, v := range commandTree(cmds) {
+		if n := utf8.RuneCountInString(v.CommandPath()); n >= width {
+			width = n + 1
+		}
+	}
+	return width
+}
+
 func hasExtraTopic(cmds []*Subcommand) bool {
-	for _, v := range cmds {
-		if !v.Runnable() {
+	for _, v := range commandTree(cmds) {
+		if v.IsTopic() {
 			return true
 		}
 	}
@@ -7,15 +7,27 @@
 package flagplus
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
 	"testing"
 
-	"github.com/tredoe/goutil/cmdutil"
+	"github.com/tredoe/goutil"
+	"github.com/tredoe/goutil/cmdutil/cmdtest"
 )
 
+func TestMain(m *testing.M) {
+	os.Exit(cmdtest.CoverMain(m))
+}
+
 func TestSubcommand(t *testing.T) {
-	cmdsInfo := []cmdutil.CommandInfo{
+	cmdsInfo := []cmdtest.CommandInfo{
 		{
-			Stderr: "Test the use of sub-command.\n",
+			Stderr:   "Test the use of sub-command.\n",
+			ExitCode: 2,
 		},
 		{
 			Args: "hello Joe",
@@ -42,10 +54,233 @@ func TestSubcommand(t *testing.T) {
 			Args: "bye -v Bill",
 			Out:  "bye Bill\nmode verbose\n",
 		},
+
+		{
+			Args:   "help hello",
+			Golden: "help-hello",
+		},
+
+		// Nested sub-commands
+		{
+			Args: "remote add origin http://foo",
+			Out:  "remote add: add origin http://foo\n",
+		},
+		{
+			Args: "-v remote add -v origin http://foo",
+			Out:  "remote add: add origin http://foo\nmode verbose\n",
+		},
+		{
+			Args: "remote list",
+			Out:  "origin\n",
+		},
+		{
+			Args:        "remote add origin",
+			StderrMatch: cmdtest.MatchContains("Usage: ./_cmd_testdata remote add NAME URL"),
+			ExitCode:    2,
+		},
+		{
+			Args:     "remote",
+			Stderr:   "Missing subcommand for \"remote\".  Run `./_cmd_testdata help remote` for usage.\n",
+			ExitCode: 2,
+		},
+		{
+			Args:     "remote foo",
+			Stderr:   "Unknown subcommand \"remote foo\".  Run `./_cmd_testdata help remote` for usage.\n",
+			ExitCode: 2,
+		},
+		{
+			Args:   "help",
+			Golden: "help",
+		},
+		{
+			Args:   "help remote",
+			Golden: "help-remote",
+		},
+		{
+			Args:   "help remote add",
+			Golden: "help-remote-add",
+		},
+		{
+			Args:     "fail 3",
+			Stderr:   "./_cmd_testdata: failed\n",
+			ExitCode: 3,
+		},
+		{
+			Args:     "interrupt",
+			Stderr:   "./_cmd_testdata: context canceled\n",
+			ExitCode: 130,
+		},
+		// The second signal exits the program.
+		{
+			Args:     "interrupt ignore",
+			ExitCode: -1,
+		},
+		{
+			Args:     "sleep",
+			ExitCode: -1,
+		},
+		{
+			Args:     "help remote foo",
+			Stderr:   "Unknown help topic \"remote foo\".  Run `./_cmd_testdata help` for usage.\n",
+			ExitCode: 2,
+		},
+	}
+
+	cmdtest.RunCommands(t, "testdata", cmdsInfo)
+}
+
+// testCommand is a command with its own flags and outputs, for ParseArgs.
+type testCommand struct {
+	*Command
+	stdout, stderr bytes.Buffer
+	verbose        *bool
+	added          []string
+}
+
+func newTestCommand() *testCommand {
+	tc := new(testCommand)
+	tc.Command = NewCommand("Test of ParseArgs.")
+	tc.Name = "prog"
+	tc.Stdout = &tc.stdout
+	tc.Stderr = &tc.stderr
+	tc.verbose = tc.Flags().Bool("v", false, "mode verbose")
+
+	cmdAdd := &Subcommand{
+		UsageLine: "add NAME",
+		Short:     "add a remote",
+		RunE: func(ctx context.Context, cmd *Subcommand, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing NAME")
+			}
+			tc.added = append(tc.added, args[0])
+			return nil
+		},
 	}
+	cmdAdd.FlagSet.Bool("force", false, "overwrite the remote")
+
+	cmdRemote := &Subcommand{
+		UsageLine: "remote command",
+		Short:     "manage remotes",
+	}
+	cmdRemote.AddSubcommands(cmdAdd)
+
+	tc.Subcommands = []*Subcommand{cmdRemote}
+	tc.AddGlobalFlags("v")
+	return tc
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		verbose  bool
+		added    string
+		err      string // prefix of the error
+		exitCode int
+		stdout   string // substring of the outputs
+		stderr   string
+	}{
+		{args: []string{"remote", "add", "origin"}, added: "origin"},
+		{args: []string{"-v", "remote", "add", "origin"}, verbose: true, added: "origin"},
+		{args: []string{"remote", "add", "-v", "-force", "origin"}, verbose: true, added: "origin"},
+
+		{args: []string{}, err: "Test of ParseArgs.", exitCode: 2},
+		{args: []string{"remote", "add"}, err: "missing NAME", exitCode: 1},
+		{
+			args:     []string{"remote", "foo"},
+			err:      "Unknown subcommand \"remote foo\".  Run `prog help remote` for usage.",
+			exitCode: 2,
+		},
+		{
+			args:     []string{"remote", "add", "-bad"},
+			err:      "flag provided but not defined: -bad",
+			exitCode: 2,
+			stderr:   "Usage: prog remote add NAME",
+		},
+		{
+			args:     []string{"-h"},
+			err:      flag.ErrHelp.Error(),
+			exitCode: 0,
+			stderr:   "Usage:\n      prog [global flags] command",
+		},
+		{args: []string{"help"}, stdout: "    remote add  add a remote"},
+		{args: []string{"help", "remote", "add"}, stdout: "Usage: prog remote add NAME"},
+	}
+
+	for i, tt := range tests {
+		// A new command for every test, all of them in the same process.
+		tc := newTestCommand()
+		err := tc.ParseArgs(tt.args)
 
-	err := cmdutil.TestCommand("testdata", cmdsInfo)
-	if err != nil {
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%d. unexpected error: %v", i, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%d. expected error %q", i, tt.err)
+		case tt.err != "" && !strings.HasPrefix(err.Error(), tt.err):
+			t.Errorf("%d. error: got %q, want %q", i, err, tt.err)
+		case err != nil && goutil.ExitCodeOf(err) != tt.exitCode:
+			t.Errorf("%d. exit code: got %d, want %d", i, goutil.ExitCodeOf(err), tt.exitCode)
+		}
+
+		if *tc.verbose != tt.verbose {
+			t.Errorf("%d. verbose: got %v", i, *tc.verbose)
+		}
+		if got := strings.Join(tc.added, ","); got != tt.added {
+			t.Errorf("%d. added: got %q, want %q", i, got, tt.added)
+		}
+		if !strings.Contains(tc.stdout.String(), tt.stdout) {
+			t.Errorf("%d. stdout: got %q, want %q", i, tc.stdout.String(), tt.stdout)
+		}
+		if !strings.Contains(tc.stderr.String(), tt.stderr) {
+			t.Errorf("%d. stderr: got %q, want %q", i, tc.stderr.String(), tt.stderr)
+		}
+	}
+}
+
+func TestExecuteArgs(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "origin")
+
+	tc := newTestCommand()
+	cmdGet := &Subcommand{
+		UsageLine: "get",
+		Short:     "get the remote of the context",
+		RunE: func(ctx context.Context, cmd *Subcommand, args []string) error {
+			tc.added = append(tc.added, ctx.Value(ctxKey{}).(string))
+			return ctx.Err()
+		},
+	}
+	tc.Subcommands[0].AddSubcommands(cmdGet)
+
+	if err := tc.ExecuteArgs(ctx, []string{"-v", "remote", "get"}); err != nil {
 		t.Fatal(err)
 	}
+	if !*tc.verbose {
+		t.Error("expected the global flag to be set")
+	}
+	if got := strings.Join(tc.added, ","); got != "origin" {
+		t.Errorf("added: got %q", got)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := tc.ExecuteArgs(ctx, []string{"remote", "get"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context canceled, got %v", err)
+	}
+}
+
+func TestParseArgsFlagError(t *testing.T) {
+	tc := newTestCommand()
+	tc.Subcommands[0].AddFlags("foo", "bar")
+
+	err := tc.ParseArgs([]string{"remote", "add", "origin"})
+	if err == nil || err.Error() != "flags do not exist: foo, bar\n" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if goutil.ExitCodeOf(err) != 2 {
+		t.Errorf("exit code: got %d", goutil.ExitCodeOf(err))
+	}
+	if tc.added != nil {
+		t.Error("the sub-command should not be run")
+	}
 }
@@ -7,11 +7,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/tredoe/goutil"
 	"github.com/tredoe/goutil/flagplus"
 )
 
@@ -24,6 +28,18 @@ var (
 	Str     = flag.String("str", "str", "flag String")
 )
 
+// interrupt sends SIGINT to the current process.
+func interrupt() {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(os.Interrupt)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 func main() {
 	cmdHello := new(flagplus.Subcommand)
 	cmdHello.UsageLine = "hello [-uppercase] NAME"
@@ -76,7 +92,91 @@ func main() {
 
 	// * * *
 
-	cmd := flagplus.NewCommand("Test the use of sub-command.", cmdHello, cmdBye)
+	cmdRemoteAdd := &flagplus.Subcommand{
+		UsageLine: "add NAME URL",
+		Short:     "add a remote",
+		Long:      `"remote add" adds a remote named NAME for the repository at URL.`,
+
+		Run: func(cmd *flagplus.Subcommand, args []string) {
+			if len(args) != 2 {
+				cmd.Usage()
+			}
+			fmt.Printf("%s: add %s %s\n", cmd.CommandPath(), args[0], args[1])
+
+			if *Verbose {
+				fmt.Println("mode verbose")
+			}
+		},
+	}
+	cmdRemoteList := &flagplus.Subcommand{
+		UsageLine: "list",
+		Short:     "list the remotes",
+		Long:      `"remote list" lists the remotes.`,
+
+		Run: func(cmd *flagplus.Subcommand, args []string) {
+			fmt.Println("origin")
+		},
+	}
+
+	cmdRemote := &flagplus.Subcommand{
+		UsageLine: "remote command [arguments]",
+		Short:     "manage remotes",
+		Long:      `"remote" manages the set of tracked repositories.`,
+	}
+	cmdRemote.AddSubcommands(cmdRemoteAdd, cmdRemoteList)
+
+	// * * *
+
+	cmdFail := &flagplus.Subcommand{
+		UsageLine: "fail CODE",
+		Short:     "return an error",
+		Long:      `"fail" returns an error with the exit status CODE.`,
+
+		RunE: func(ctx context.Context, cmd *flagplus.Subcommand, args []string) error {
+			code := 1
+			if len(args) == 1 {
+				fmt.Sscan(args[0], &code)
+			}
+			return goutil.WithExitCode(errors.New("failed"), code)
+		},
+	}
+	cmdInterrupt := &flagplus.Subcommand{
+		UsageLine: "interrupt [ignore]",
+		Short:     "interrupt itself",
+		Long: `"interrupt" sends SIGINT to itself, and waits for the context to be cancelled.
+With "ignore", it sends a second SIGINT, and it ignores the context.`,
+
+		RunE: func(ctx context.Context, cmd *flagplus.Subcommand, args []string) error {
+			interrupt()
+			<-ctx.Done()
+			if len(args) == 0 {
+				return ctx.Err()
+			}
+
+			interrupt()
+			time.Sleep(3 * time.Second)
+			fmt.Println("finished sleeping")
+			return nil
+		},
+	}
+	cmdSleep := &flagplus.Subcommand{
+		UsageLine: "sleep",
+		Short:     "interrupt itself twice while sleeping",
+		Long:      `"sleep" sends SIGINT to itself twice, and sleeps without handling it.`,
+
+		Run: func(cmd *flagplus.Subcommand, args []string) {
+			interrupt()
+			time.Sleep(100 * time.Millisecond)
+			interrupt()
+			time.Sleep(3 * time.Second)
+			fmt.Println("finished sleeping")
+		},
+	}
+
+	// * * *
+
+	cmd := flagplus.NewCommand("Test the use of sub-command.",
+		cmdHello, cmdBye, cmdRemote, cmdFail, cmdInterrupt, cmdSleep)
 	cmd.AddGlobalFlags("v", "str")
-	cmd.Parse()
+	cmd.Execute(context.Background())
 }
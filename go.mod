@@ -1,6 +1,6 @@
 module github.com/tredoe/goutil
 
-go 1.16
+go 1.20
 
 require (
     github.com/tredoe/osutil v1.0.6
@@ -0,0 +1,67 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package term handles terminals without cgo.
+package term
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+var errNoFd = errors.New("term: no file descriptor")
+
+// IsTerminal reports whether v is a file descriptor connected to a terminal.
+// It is true when v has a method "Fd() uintptr", like *os.File, and the
+// descriptor is a terminal.
+func IsTerminal(v interface{}) bool {
+	f, ok := v.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+	return isTerminal(f.Fd())
+}
+
+// ColorEnabled reports whether the output to v has to be colored, following
+// the conventions of the environment variables NO_COLOR and CLICOLOR_FORCE:
+// it is not colored if NO_COLOR is set and not empty; else it is colored if
+// CLICOLOR_FORCE is set and not "0", or if v is a terminal.
+func ColorEnabled(v interface{}) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if s := os.Getenv("CLICOLOR_FORCE"); s != "" && s != "0" {
+		return true
+	}
+	return IsTerminal(v)
+}
+
+// DisableEcho disables the echo of the characters typed in the terminal v,
+// which has to have a method "Fd() uintptr".
+// The returned function restores the previous state.
+func DisableEcho(v interface{}) (restore func() error, err error) {
+	f, ok := v.(interface{ Fd() uintptr })
+	if !ok {
+		return nil, errNoFd
+	}
+	return disableEcho(f.Fd())
+}
+
+// Width returns the number of columns of the terminal v, which has to have a
+// method "Fd() uintptr". If the width can not be got from the terminal, it is
+// got from the environment variable COLUMNS.
+func Width(v interface{}) (int, bool) {
+	if f, ok := v.(interface{ Fd() uintptr }); ok {
+		if n := width(f.Fd()); n > 0 {
+			return n, true
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,20 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!windows
+
+package term
+
+import "errors"
+
+func isTerminal(fd uintptr) bool { return false }
+
+func disableEcho(fd uintptr) (func() error, error) {
+	return nil, errors.New("term: not supported")
+}
+
+func width(fd uintptr) int { return 0 }
@@ -0,0 +1,54 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func isTerminal(fd uintptr) bool {
+	var t syscall.Termios
+	return ioctl(fd, ioctlReadTermios, &t) == nil
+}
+
+func disableEcho(fd uintptr) (func() error, error) {
+	var t syscall.Termios
+	if err := ioctl(fd, ioctlReadTermios, &t); err != nil {
+		return nil, err
+	}
+	old := t
+
+	t.Lflag &^= syscall.ECHO
+	t.Lflag |= syscall.ICANON | syscall.ISIG
+	t.Iflag |= syscall.ICRNL
+	if err := ioctl(fd, ioctlWriteTermios, &t); err != nil {
+		return nil, err
+	}
+	return func() error { return ioctl(fd, ioctlWriteTermios, &old) }, nil
+}
+
+func width(fd uintptr) int {
+	var ws struct{ row, col, xpixel, ypixel uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ,
+		uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0
+	}
+	return int(ws.col)
+}
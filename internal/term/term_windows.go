@@ -0,0 +1,60 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+const enableEchoInput = 0x4
+
+func setConsoleMode(h syscall.Handle, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func disableEcho(fd uintptr) (func() error, error) {
+	h := syscall.Handle(fd)
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	if err := setConsoleMode(h, mode&^enableEchoInput); err != nil {
+		return nil, err
+	}
+	return func() error { return setConsoleMode(h, mode) }, nil
+}
+
+func width(fd uintptr) int {
+	type coord struct{ x, y int16 }
+	var info struct {
+		size, cursorPosition     coord
+		attributes               uint16
+		left, top, right, bottom int16
+		maximumWindowSize        coord
+	}
+	r, _, _ := procGetConsoleScreenBufferInfo.Call(fd, uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0
+	}
+	return int(info.right-info.left) + 1
+}
@@ -0,0 +1,102 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merrors
+
+import "sync"
+
+// A Collector gathers errors from multiple goroutines.
+//
+// Unlike an errgroup, it does not stop at the first error; every failure is
+// kept until the limit given in Max is reached.
+// The zero value is ready to use.
+type Collector struct {
+	// Max is the maximum number of errors to collect; 0 means no limit.
+	// The errors beyond that limit are counted but discarded.
+	// It must not be changed after the first use of the Collector.
+	Max int
+
+	mu      sync.Mutex
+	list    ListError
+	dropped int
+	wg      sync.WaitGroup
+}
+
+// Add adds an Error with given error message.
+func (c *Collector) Add(msg string) {
+	c.add(Error{Msg: msg})
+}
+
+// AddError adds the error err, like in (*ListError).AddError.
+func (c *Collector) AddError(err error) {
+	var list ListError
+	list.AddError(err)
+	c.add(list...)
+}
+
+func (c *Collector) add(errs ...Error) {
+	if len(errs) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Max > 0 {
+		if free := c.Max - len(c.list); free < len(errs) {
+			if free < 0 {
+				free = 0
+			}
+			c.dropped += len(errs) - free
+			errs = errs[:free]
+		}
+	}
+	c.list = append(c.list, errs...)
+}
+
+// Go calls the function f in a new goroutine, adding the error returned
+// by it, if any.
+func (c *Collector) Go(f func() error) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := f(); err != nil {
+			c.AddError(err)
+		}
+	}()
+}
+
+// Wait blocks until all function calls from the Go method have returned,
+// then it returns the result of Err.
+func (c *Collector) Wait() error {
+	c.wg.Wait()
+	return c.Err()
+}
+
+// Err returns a ListError with a copy of the errors collected.
+// If there are no errors, Err returns nil.
+func (c *Collector) Err() error {
+	return c.List().Err()
+}
+
+// List returns a copy of the errors collected.
+func (c *Collector) List() ListError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.list) == 0 {
+		return nil
+	}
+	list := make(ListError, len(c.list))
+	copy(list, c.list)
+	return list
+}
+
+// Dropped returns the number of errors discarded due to the limit in Max.
+func (c *Collector) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
@@ -0,0 +1,229 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merrors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldErrors holds the errors found validating structured data, grouped by
+// the path of the field, like "servers[2].tls.cert".
+// Every path can have multiple errors.
+//
+// The paths are built through the methods Field and Index:
+//
+//	var errs merrors.FieldErrors
+//	errs.Field("servers").Index(2).Field("tls").Add("certificate not found")
+//
+// The zero value is ready to use.
+type FieldErrors struct {
+	paths []string // in order of insertion
+	errs  map[string]ListError
+}
+
+// Field returns the path to the field name at the top level.
+func (e *FieldErrors) Field(name string) FieldPath {
+	return FieldPath{e, name}
+}
+
+// Index returns the path to the element i at the top level, for when the
+// data validated is a list.
+func (e *FieldErrors) Index(i int) FieldPath {
+	return FieldPath{e, indexPath(i)}
+}
+
+// Add adds an error message which refers to the whole data.
+func (e *FieldErrors) Add(msg string) {
+	FieldPath{e, ""}.Add(msg)
+}
+
+// Merge adds the errors of child at the top level.
+func (e *FieldErrors) Merge(child *FieldErrors) {
+	FieldPath{e, ""}.Merge(child)
+}
+
+func (e *FieldErrors) add(path string, errs ...Error) {
+	if len(errs) == 0 {
+		return
+	}
+	if e.errs == nil {
+		e.errs = make(map[string]ListError)
+	}
+	if _, found := e.errs[path]; !found {
+		e.paths = append(e.paths, path)
+	}
+	for i := range errs {
+		errs[i].Key = path
+	}
+	e.errs[path] = append(e.errs[path], errs...)
+}
+
+// Len returns the number of errors.
+func (e *FieldErrors) Len() int {
+	if e == nil {
+		return 0
+	}
+	n := 0
+	for _, v := range e.errs {
+		n += len(v)
+	}
+	return n
+}
+
+// Paths returns the paths with errors, in order of insertion.
+func (e *FieldErrors) Paths() []string {
+	if e == nil {
+		return nil
+	}
+	paths := make([]string, len(e.paths))
+	copy(paths, e.paths)
+	return paths
+}
+
+// Get returns the errors of the given path.
+func (e *FieldErrors) Get(path string) ListError {
+	if e == nil {
+		return nil
+	}
+	return e.errs[path]
+}
+
+// Flatten returns all errors in order of insertion of their paths.
+// The key of every Error is its path.
+func (e *FieldErrors) Flatten() ListError {
+	if e == nil {
+		return nil
+	}
+	var list ListError
+	for _, path := range e.paths {
+		list = append(list, e.errs[path]...)
+	}
+	return list
+}
+
+// MapError returns the errors mapped by path.
+// The errors of a path with multiple errors are combined in a single Error,
+// whose message is the one of every error separated by "; ", and which wraps
+// them as a ListError.
+func (e *FieldErrors) MapError() MapError {
+	if e == nil {
+		return nil
+	}
+	m := make(MapError, len(e.paths))
+	for _, path := range e.paths {
+		list := e.errs[path]
+		if len(list) == 1 {
+			m[path] = list[0]
+			continue
+		}
+
+		msgs := make([]string, len(list))
+		for i, v := range list {
+			msgs[i] = v.Error()
+		}
+		m[path] = Error{Key: path, Msg: strings.Join(msgs, "; "), Err: list}
+	}
+	return m
+}
+
+// Err returns an error equivalent to these field errors.
+// If there are no errors, or e is nil, Err returns nil.
+func (e *FieldErrors) Err() error {
+	if e.Len() == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error implements the error interface.
+// The errors are rendered according to DefaultFormat.
+func (e *FieldErrors) Error() string {
+	return DefaultFormat.text(e.Flatten())
+}
+
+// Unwrap returns the errors in order of insertion of their paths.
+// It is used by the functions errors.Is and errors.As.
+func (e *FieldErrors) Unwrap() []error {
+	return e.Flatten().Unwrap()
+}
+
+// * * *
+
+// FieldPath represents the path to a field in a FieldErrors.
+type FieldPath struct {
+	errs *FieldErrors
+	path string
+}
+
+// Field returns the path to the field name, under p.
+func (p FieldPath) Field(name string) FieldPath {
+	return FieldPath{p.errs, joinPath(p.path, name)}
+}
+
+// Index returns the path to the element i of the list in p.
+func (p FieldPath) Index(i int) FieldPath {
+	return FieldPath{p.errs, p.path + indexPath(i)}
+}
+
+// String returns the path, like "servers[2].tls".
+func (p FieldPath) String() string {
+	return p.path
+}
+
+// Add adds an error message at the path.
+func (p FieldPath) Add(msg string) {
+	p.errs.add(p.path, Error{Msg: msg})
+}
+
+// Addf adds an error formatted according to a format specifier, at the path.
+// The verb %w can be used to wrap an error, like in fmt.Errorf.
+func (p FieldPath) Addf(format string, a ...interface{}) {
+	p.errs.add(p.path, Error{Err: fmt.Errorf(format, a...)})
+}
+
+// AddError adds the error err at the path, like in (*ListError).AddError.
+// The errors with key, like the ones of a MapError or FieldErrors, are added
+// at their key under the path.
+func (p FieldPath) AddError(err error) {
+	var list ListError
+	list.AddError(err)
+	for _, v := range list {
+		p.errs.add(joinPath(p.path, v.Key), v)
+	}
+}
+
+// Merge adds the errors of child, the result of validating the data at the
+// path, so that their paths are prefixed by p.
+func (p FieldPath) Merge(child *FieldErrors) {
+	if child == nil {
+		return
+	}
+	for _, path := range child.paths {
+		list := make(ListError, len(child.errs[path]))
+		copy(list, child.errs[path])
+		p.errs.add(joinPath(p.path, path), list...)
+	}
+}
+
+// joinPath returns the path to sub under the path parent.
+func joinPath(parent, sub string) string {
+	switch {
+	case parent == "":
+		return sub
+	case sub == "":
+		return parent
+	case sub[0] == '[':
+		return parent + sub
+	}
+	return parent + "." + sub
+}
+
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
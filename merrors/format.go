@@ -0,0 +1,104 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merrors
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format represents the options to render a ListError or a MapError.
+type Format struct {
+	Max       int    // maximum number of errors shown; 0 means all
+	Separator string // string between errors; a newline if it is empty
+	Keys      bool   // prefix every error with its key, if any, like "key: message"
+	Indent    string // string at the start of every line
+}
+
+// DefaultFormat is the format used by the Error methods.
+var DefaultFormat = Format{Max: 4, Keys: true}
+
+// printFormat is the format used by PrintError and by the verb %+v.
+var printFormat = Format{Keys: true}
+
+// text returns the errors in list rendered according to the format.
+func (f Format) text(list ListError) string {
+	if len(list) == 0 {
+		return "no errors"
+	}
+
+	sep := f.Separator
+	if sep == "" {
+		sep = "\n"
+	}
+	shown := len(list)
+	if f.Max > 0 && shown > f.Max {
+		shown = f.Max
+	}
+
+	var b strings.Builder
+	for i, v := range list[:shown] {
+		if i != 0 {
+			b.WriteString(sep)
+		}
+		msg := v.Error()
+		if f.Keys && v.Key != "" {
+			msg = v.Key + ": " + msg
+		}
+		b.WriteString(f.indent(msg))
+	}
+	if shown < len(list) {
+		b.WriteString(sep)
+		b.WriteString(f.indent(fmt.Sprintf("(and %d more errors)", len(list)-shown)))
+	}
+	return b.String()
+}
+
+// indent adds the indentation at the start of every line in s.
+func (f Format) indent(s string) string {
+	if f.Indent == "" {
+		return s
+	}
+	return f.Indent + strings.ReplaceAll(s, "\n", "\n"+f.Indent)
+}
+
+// fprint prints the errors in list to w, followed by a newline.
+// It prints nothing if the list is empty.
+func (f Format) fprint(w io.Writer, list ListError) error {
+	if len(list) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w, f.text(list)+"\n")
+	return err
+}
+
+// formatState implements fmt.Formatter for the errors in list.
+// The verb %#v prints the collection v in Go syntax, through its underlying
+// value, which does not implement fmt.Formatter.
+func formatState(s fmt.State, verb rune, list ListError, v, underlying interface{}) {
+	switch verb {
+	case 'v':
+		if s.Flag('#') {
+			text := fmt.Sprintf("%#v", underlying)
+			fmt.Fprintf(s, "%T%s", v, strings.TrimPrefix(text, fmt.Sprintf("%T", underlying)))
+			return
+		}
+		if s.Flag('+') {
+			io.WriteString(s, printFormat.text(list))
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, DefaultFormat.text(list))
+	case 'q':
+		io.WriteString(s, strconv.Quote(DefaultFormat.text(list)))
+	default:
+		fmt.Fprintf(s, "%%!%c(merrors=%s)", verb, DefaultFormat.text(list))
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merrors
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+)
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, v := range severityNames {
+		if v == string(text) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("merrors: unknown severity %q", text)
+}
+
+// jsonError is the JSON representation of an Error.
+type jsonError struct {
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+	Key      string   `json:"key,omitempty"`
+	Pos      *jsonPos `json:"pos,omitempty"`
+}
+
+// jsonPos is the JSON representation of a token.Position.
+type jsonPos struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset,omitempty"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// An Error is encoded as an object with the fields "message" and "severity",
+// and the optional fields "key" and "pos"; this one is an object with the
+// fields "filename", "offset", "line" and "column".
+// The underlying error is encoded through its message.
+func (e Error) MarshalJSON() ([]byte, error) {
+	v := jsonError{
+		Message:  e.message(),
+		Severity: e.Severity,
+		Key:      e.Key,
+	}
+	if e.Pos.Filename != "" || e.Pos.IsValid() {
+		v.Pos = &jsonPos{
+			Filename: e.Pos.Filename,
+			Offset:   e.Pos.Offset,
+			Line:     e.Pos.Line,
+			Column:   e.Pos.Column,
+		}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// The message is stored in the field Msg.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var v jsonError
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*e = Error{
+		Severity: v.Severity,
+		Key:      v.Key,
+		Msg:      v.Message,
+	}
+	if v.Pos != nil {
+		e.Pos = token.Position{
+			Filename: v.Pos.Filename,
+			Offset:   v.Pos.Offset,
+			Line:     v.Pos.Line,
+			Column:   v.Pos.Column,
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// A ListError is encoded as an array of errors; an empty list as "[]".
+func (e ListError) MarshalJSON() ([]byte, error) {
+	if e == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Error(e))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *ListError) UnmarshalJSON(data []byte) error {
+	var list []Error
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*e = list
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// It returns every error, one per line.
+func (e ListError) MarshalText() ([]byte, error) {
+	if len(e) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(printFormat.text(e)), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// A MapError is encoded as an object which maps every key to the error
+// message, like {"name": "is required"}; an empty map as "{}".
+func (e MapError) MarshalJSON() ([]byte, error) {
+	m := make(map[string]string, len(e))
+	for k, v := range e {
+		m[k] = v.message()
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// The messages are stored as it is done by the method Set.
+func (e *MapError) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if *e == nil {
+		*e = make(MapError, len(m))
+	}
+	for k, v := range m {
+		e.Set(k, v)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// It returns every error sorted by key, one per line.
+func (e MapError) MarshalText() ([]byte, error) {
+	if len(e) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(printFormat.text(e.list())), nil
+}
@@ -9,49 +9,162 @@
 package merrors
 
 import (
+	"errors"
 	"fmt"
+	"go/scanner"
+	"go/token"
 	"io"
-	"strings"
+	"reflect"
+	"sort"
+	"strconv"
 )
 
+// Severity indicates how serious an Error is.
+type Severity int
+
+// Severities of an Error. The zero value is SeverityError.
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+var severityNames = [...]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "info",
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "severity(" + strconv.Itoa(int(s)) + ")"
+}
+
 // In a ListError or MapError, an error is represented by an Error.
-type Error string
+//
+// The Pos field is optional; if it is valid, it is printed before the message.
+// If Err is not nil and Msg is empty, the message is the one of Err.
+type Error struct {
+	Pos      token.Position // position in a source file, if any
+	Severity Severity
+	Key      string // key or field which the error refers to
+	Msg      string
+	Err      error // underlying error, if any
+}
 
 // Error implements the error interface.
 func (e Error) Error() string {
-	return string(e)
+	msg := e.message()
+	if e.Severity != SeverityError {
+		msg = e.Severity.String() + ": " + msg
+	}
+	if e.Pos.Filename != "" || e.Pos.IsValid() {
+		return e.Pos.String() + ": " + msg
+	}
+	return msg
+}
+
+// message returns the message of the error, without position nor severity.
+func (e Error) message() string {
+	if e.Msg == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Msg
+}
+
+// Unwrap returns the underlying error.
+func (e Error) Unwrap() error {
+	return e.Err
 }
 
 // ListError is a list of errors.
 type ListError []Error
 
-// Add adds an Error with given position and error message to a ListError.
+// Add adds an Error with given error message to a ListError.
 func (e *ListError) Add(msg string) {
-	*e = append(*e, Error(msg))
+	*e = append(*e, Error{Msg: msg})
 }
 
-// A ListError implements the error interface.
-func (e ListError) Error() string {
-	switch len(e) {
-	case 0:
-		return "no errors"
-	case 1:
-		return e[0].Error()
-	}
+// Addf adds an Error formatted according to a format specifier.
+// The verb %w can be used to wrap an error, like in fmt.Errorf.
+func (e *ListError) Addf(format string, a ...interface{}) {
+	*e = append(*e, Error{Err: fmt.Errorf(format, a...)})
+}
 
-	listOut := make([]string, 0)
-	for i, v := range e {
-		if i == 4 {
-			break
+// AddError adds the error err, keeping it as underlying error.
+// If err is an Error, it is added as is.
+//
+// A ListError, MapError, FieldErrors or scanner.ErrorList is added entry by
+// entry, and so is an error built by errors.Join, so that the list holds every
+// single error. Any other error which wraps multiple errors is added as a
+// single error, to keep its message; its errors are still found by errors.Is
+// and errors.As.
+func (e *ListError) AddError(err error) {
+	switch v := err.(type) {
+	case nil:
+	case Error:
+		*e = append(*e, v)
+	case ListError:
+		*e = append(*e, v...)
+	case MapError:
+		*e = append(*e, v.list()...)
+	case *FieldErrors:
+		*e = append(*e, v.Flatten()...)
+	case scanner.ErrorList:
+		for _, err := range v {
+			e.AddPos(err.Pos, err.Msg)
+		}
+	case *scanner.Error:
+		e.AddPos(v.Pos, v.Msg)
+	default:
+		if errs, ok := joined(err); ok {
+			for _, err := range errs {
+				e.AddError(err)
+			}
+			return
 		}
-		listOut = append(listOut, v.Error())
+		*e = append(*e, Error{Err: err})
 	}
+}
+
+// AddPos adds an Error with given position and error message to a ListError.
+func (e *ListError) AddPos(pos token.Position, msg string) {
+	*e = append(*e, Error{Pos: pos, Msg: msg})
+}
+
+// A ListError implements the error interface.
+// The errors are rendered according to DefaultFormat.
+func (e ListError) Error() string {
+	return DefaultFormat.text(e)
+}
+
+// Fprint prints the list of errors to w, according to the format f,
+// followed by a newline.
+func (e ListError) Fprint(w io.Writer, f Format) error {
+	return f.fprint(w, e)
+}
 
-	out := strings.Join(listOut, "\n")
-	if len(e) > 4 {
-		out += fmt.Sprintf("\n(and %d more errors)", len(e)-4)
+// Format implements fmt.Formatter.
+// The verb %+v prints every error, one per line, and %#v the list in Go
+// syntax.
+func (e ListError) Format(s fmt.State, verb rune) {
+	formatState(s, verb, e, e, []Error(e))
+}
+
+// Unwrap returns the errors in the list.
+// It is used by the functions errors.Is and errors.As.
+func (e ListError) Unwrap() []error {
+	if len(e) == 0 {
+		return nil
 	}
-	return out
+	errs := make([]error, len(e))
+	for i, v := range e {
+		errs[i] = v
+	}
+	return errs
 }
 
 // Err returns an error equivalent to this error list.
@@ -68,7 +181,21 @@ type MapError map[string]Error
 
 // Set sets the key to value.
 func (e MapError) Set(key, value string) {
-	e[key] = Error(value)
+	e[key] = Error{Key: key, Msg: value}
+}
+
+// SetError sets the key to the error err, keeping it as underlying error.
+// If err is an Error, it is stored with its key set to key.
+func (e MapError) SetError(key string, err error) {
+	if err == nil {
+		return
+	}
+	if v, ok := err.(Error); ok {
+		v.Key = key
+		e[key] = v
+		return
+	}
+	e[key] = Error{Key: key, Err: err}
 }
 
 // Err returns an error equivalent to this error map.
@@ -80,46 +207,81 @@ func (e MapError) Err() error {
 	return e
 }
 
-// An Error implements the error interface.
-func (e MapError) Error() string {
-	switch len(e) {
-	case 0:
-		return "no errors"
-	case 1:
-		for _, v := range e {
-			return fmt.Sprintf("%s", v)
-		}
+// Unwrap returns the errors in the map, sorted by key.
+// It is used by the functions errors.Is and errors.As.
+func (e MapError) Unwrap() []error {
+	if len(e) == 0 {
+		return nil
+	}
+	list := e.list()
+	errs := make([]error, len(list))
+	for i, v := range list {
+		errs[i] = v
 	}
+	return errs
+}
+
+// list returns the errors in the map, sorted by key.
+// The key of an Error is set from the map, if it is empty.
+func (e MapError) list() ListError {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-	listOut := make([]string, 0)
-	i := 0
-	for _, v := range e {
-		if i == 4 {
-			break
+	list := make(ListError, len(keys))
+	for i, k := range keys {
+		v := e[k]
+		if v.Key == "" {
+			v.Key = k
 		}
-		listOut = append(listOut, v.Error())
-		i++
+		list[i] = v
 	}
+	return list
+}
+
+// An Error implements the error interface.
+// The errors are sorted by key, and rendered according to DefaultFormat.
+func (e MapError) Error() string {
+	return DefaultFormat.text(e.list())
+}
 
-	out := strings.Join(listOut, "\n")
-	if len(e) > 4 {
-		out += fmt.Sprintf("\n(and %d more errors)", len(e)-4)
+// Fprint prints the errors sorted by key to w, according to the format f,
+// followed by a newline.
+func (e MapError) Fprint(w io.Writer, f Format) error {
+	return f.fprint(w, e.list())
+}
+
+// Format implements fmt.Formatter.
+// The verb %+v prints every error, one per line, and %#v the map in Go
+// syntax.
+func (e MapError) Format(s fmt.State, verb rune) {
+	formatState(s, verb, e.list(), e, map[string]Error(e))
+}
+
+// joinType is the type of the errors built by errors.Join.
+var joinType = reflect.TypeOf(errors.Join(errors.New("")))
+
+// joined returns the errors wrapped by err, if it was built by errors.Join.
+func joined(err error) ([]error, bool) {
+	if reflect.TypeOf(err) != joinType {
+		return nil, false
 	}
-	return out
+	return err.(interface{ Unwrap() []error }).Unwrap(), true
 }
 
 // PrintError is a utility function that prints a list of errors to w, one error per line,
 // if the err parameter is a ListError or a MapError. Otherwise it prints the err string.
+// See Printer for an output more suitable to command-line programs.
 func PrintError(w io.Writer, err error) {
-	if listErr, ok := err.(ListError); ok {
-		for _, e := range listErr {
-			fmt.Fprintf(w, "%s\n", e)
-		}
-	} else if mapErr, ok := err.(MapError); ok {
-		for _, e := range mapErr {
-			fmt.Fprintf(w, "%s\n", e)
-		}
-	} else if err != nil {
+	switch e := err.(type) {
+	case nil:
+	case ListError:
+		e.Fprint(w, printFormat)
+	case MapError:
+		e.Fprint(w, printFormat)
+	default:
 		fmt.Fprintf(w, "%s\n", err)
 	}
 }
@@ -0,0 +1,364 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merrors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListError(t *testing.T) {
+	var e ListError
+	if e.Err() != nil {
+		t.Fatal("expected nil error for an empty list")
+	}
+
+	e.Add("foo")
+	e.AddPos(token.Position{Filename: "a.go", Line: 3, Column: 5}, "bar")
+	e.Addf("open %s: %w", "x", fs.ErrNotExist)
+	e.AddError(Error{Severity: SeverityWarning, Msg: "deprecated"})
+	e.AddError(nil)
+
+	if len(e) != 4 {
+		t.Fatalf("expected 4 errors, got %d", len(e))
+	}
+
+	tests := []string{
+		"foo",
+		"a.go:3:5: bar",
+		"open x: file does not exist",
+		"warning: deprecated",
+	}
+	for i, want := range tests {
+		if got := e[i].Error(); got != want {
+			t.Errorf("%d. got %q, want %q", i, got, want)
+		}
+	}
+
+	if !errors.Is(e[2], fs.ErrNotExist) {
+		t.Error("expected the wrapped error to be kept")
+	}
+}
+
+func TestMapError(t *testing.T) {
+	e := make(MapError)
+	e.Set("name", "is required")
+	e.SetError("path", fs.ErrNotExist)
+
+	if e["name"].Key != "name" || e["name"].Error() != "is required" {
+		t.Errorf("unexpected entry: %+v", e["name"])
+	}
+	if !errors.Is(e["path"], fs.ErrNotExist) {
+		t.Error("expected the wrapped error to be kept")
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	errFoo := errors.New("foo")
+
+	var list ListError
+	list.Add("bar")
+	list.AddError(fs.ErrNotExist)
+
+	if !errors.Is(list, fs.ErrNotExist) {
+		t.Error("ListError: expected to match fs.ErrNotExist")
+	}
+	if errors.Is(list, errFoo) {
+		t.Error("ListError: unexpected match")
+	}
+
+	m := make(MapError)
+	m.SetError("file", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission})
+
+	var pathErr *fs.PathError
+	if !errors.As(m, &pathErr) || pathErr.Path != "x" {
+		t.Error("MapError: expected to find *fs.PathError")
+	}
+	if !errors.Is(m, fs.ErrPermission) {
+		t.Error("MapError: expected to match fs.ErrPermission")
+	}
+
+	// errors.Join wrapping a ListError
+	if !errors.Is(errors.Join(errFoo, list), fs.ErrNotExist) {
+		t.Error("errors.Join: expected to match fs.ErrNotExist")
+	}
+
+	// ListError holding an errors.Join
+	var list2 ListError
+	list2.AddError(errors.Join(errFoo, m))
+	if len(list2) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(list2))
+	}
+	if !errors.Is(list2, errFoo) || !errors.Is(list2, fs.ErrPermission) {
+		t.Error("expected to match the joined errors")
+	}
+	if list2[1].Key != "file" {
+		t.Errorf("expected key %q, got %q", "file", list2[1].Key)
+	}
+
+	// Other errors wrapping multiple errors are kept as single errors
+	var list3 ListError
+	list3.AddError(multiError{errFoo, nil, fs.ErrExist})
+	list3.AddError(fmt.Errorf("open: %w; close: %w", fs.ErrNotExist, fs.ErrClosed))
+	if len(list3) != 2 {
+		t.Fatalf("expected 2 errors, got %d:\n%+v", len(list3), list3)
+	}
+	if msg := list3[0].Error(); msg != "3 errors" {
+		t.Errorf("multiError: expected a single error, got %q", msg)
+	}
+	if msg := list3[1].Error(); msg != "open: file does not exist; close: file already closed" {
+		t.Errorf("fmt.Errorf: expected a single error, got %q", msg)
+	}
+	for _, target := range []error{errFoo, fs.ErrExist, fs.ErrClosed} {
+		if !errors.Is(list3, target) {
+			t.Errorf("expected to match %v", target)
+		}
+	}
+}
+
+// multiError wraps multiple errors, with a message different from the one of
+// errors.Join.
+type multiError []error
+
+func (e multiError) Error() string   { return fmt.Sprintf("%d errors", len(e)) }
+func (e multiError) Unwrap() []error { return e }
+
+func TestCollector(t *testing.T) {
+	var c Collector
+	for i := 0; i < 10; i++ {
+		i := i
+		c.Go(func() error {
+			if i%2 == 0 {
+				return fs.ErrNotExist
+			}
+			return nil
+		})
+	}
+	err := c.Wait()
+
+	list, ok := err.(ListError)
+	if !ok {
+		t.Fatalf("expected a ListError, got %T", err)
+	}
+	if len(list) != 5 {
+		t.Errorf("expected 5 errors, got %d", len(list))
+	}
+
+	c = Collector{Max: 2}
+	for i := 0; i < 4; i++ {
+		c.Go(func() error { return fs.ErrPermission })
+	}
+	if err = c.Wait(); len(err.(ListError)) != 2 || c.Dropped() != 2 {
+		t.Errorf("expected 2 errors and 2 dropped, got %d and %d",
+			len(err.(ListError)), c.Dropped())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := make(MapError)
+	for _, k := range []string{"e", "d", "c", "b", "a"} {
+		m.Set(k, "invalid")
+	}
+
+	want := "a: invalid\nb: invalid\nc: invalid\nd: invalid\n(and 1 more errors)"
+	if got := m.Error(); got != want {
+		t.Errorf("Error: got %q, want %q", got, want)
+	}
+
+	want = "a: invalid\nb: invalid\nc: invalid\nd: invalid\ne: invalid"
+	if got := fmt.Sprintf("%+v", m); got != want {
+		t.Errorf("%%+v: got %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	m.Fprint(&buf, Format{Max: 2, Separator: "; ", Indent: "  "})
+	want = "  invalid;   invalid;   (and 3 more errors)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Fprint: got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	PrintError(&buf, m)
+	if got := buf.String(); got != fmt.Sprintf("%+v\n", m) {
+		t.Errorf("PrintError: got %q", got)
+	}
+
+	// Go syntax
+	m2 := MapError{"a": m["a"]}
+	want = `merrors.MapError{"a":merrors.Error{Pos:token.Position{Filename:"", Offset:0, Line:0, Column:0}, Severity:0, Key:"a", Msg:"invalid", Err:error(nil)}}`
+	if got := fmt.Sprintf("%#v", m2); got != want {
+		t.Errorf("%%#v: got %q, want %q", got, want)
+	}
+	list := m.list()
+	want = `merrors.ListError{merrors.Error{Pos:token.Position{Filename:"", Offset:0, Line:0, Column:0}, Severity:0, Key:"a", Msg:"invalid", Err:error(nil)}`
+	if got := fmt.Sprintf("%#v", list[:1]); got != want+"}" {
+		t.Errorf("%%#v: got %q, want %q", got, want+"}")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	m := make(MapError)
+	m.Set("name", "is required")
+	m.SetError("path", fs.ErrNotExist)
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"is required","path":"file does not exist"}`
+	if string(data) != want {
+		t.Errorf("MapError: got %s, want %s", data, want)
+	}
+
+	var m2 MapError
+	if err = json.Unmarshal(data, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if m2.Error() != m.Error() {
+		t.Errorf("MapError: got %q, want %q", m2.Error(), m.Error())
+	}
+
+	var list ListError
+	list.Add("foo")
+	list.AddError(Error{
+		Pos:      token.Position{Filename: "a.go", Line: 3, Column: 5},
+		Severity: SeverityWarning,
+		Key:      "bar",
+		Msg:      "unused",
+	})
+
+	data, err = json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `[{"message":"foo","severity":"error"},` +
+		`{"message":"unused","severity":"warning","key":"bar",` +
+		`"pos":{"filename":"a.go","line":3,"column":5}}]`
+	if string(data) != want {
+		t.Errorf("ListError: got %s, want %s", data, want)
+	}
+
+	var list2 ListError
+	if err = json.Unmarshal(data, &list2); err != nil {
+		t.Fatal(err)
+	}
+	if len(list2) != 2 || list2[0] != list[0] || list2[1] != list[1] {
+		t.Errorf("ListError: got %#v, want %#v", list2, list)
+	}
+
+	if data, _ = json.Marshal(ListError(nil)); string(data) != "[]" {
+		t.Errorf("ListError: got %s for an empty list", data)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	var tlsErrs FieldErrors
+	tlsErrs.Field("cert").Add("not found")
+	tlsErrs.Field("cert").Addf("open: %w", fs.ErrNotExist)
+
+	var errs FieldErrors
+	errs.Field("name").Add("is required")
+	srv := errs.Field("servers").Index(2)
+	srv.Field("port").Add("out of range")
+	srv.Field("tls").Merge(&tlsErrs)
+
+	if errs.Len() != 4 {
+		t.Fatalf("expected 4 errors, got %d", errs.Len())
+	}
+	if !errors.Is(errs.Err(), fs.ErrNotExist) {
+		t.Error("expected to match fs.ErrNotExist")
+	}
+
+	want := "name: is required\n" +
+		"servers[2].port: out of range\n" +
+		"servers[2].tls.cert: not found\n" +
+		"servers[2].tls.cert: open: file does not exist"
+	if got := fmt.Sprintf("%+v", errs.Flatten()); got != want {
+		t.Errorf("Flatten: got %q, want %q", got, want)
+	}
+
+	m := errs.MapError()
+	if len(m) != 3 {
+		t.Fatalf("MapError: expected 3 keys, got %d", len(m))
+	}
+	want = "not found; open: file does not exist"
+	if got := m["servers[2].tls.cert"].Error(); got != want {
+		t.Errorf("MapError: got %q, want %q", got, want)
+	}
+
+	// Errors with key added under a path
+	var errs2 FieldErrors
+	errs2.Field("servers").Index(2).AddError(MapError{"port": {Msg: "out of range"}})
+	errs2.Field("servers").AddError(&tlsErrs)
+	want = "servers[2].port: out of range\n" +
+		"servers.cert: not found\n" +
+		"servers.cert: open: file does not exist"
+	if got := fmt.Sprintf("%+v", errs2.Flatten()); got != want {
+		t.Errorf("AddError: got %q, want %q", got, want)
+	}
+
+	// nil FieldErrors
+	var nilErrs *FieldErrors
+	if nilErrs.Len() != 0 || nilErrs.Flatten() != nil || nilErrs.Err() != nil {
+		t.Error("expected no errors from a nil FieldErrors")
+	}
+	var list ListError
+	list.AddError(nilErrs)
+	if len(list) != 0 {
+		t.Errorf("expected no errors adding a nil FieldErrors, got %d", len(list))
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(filename, []byte("package a\n\tfoo(x)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var list scanner.ErrorList
+	list.Add(token.Position{Filename: filename, Line: 2, Column: 6}, "undefined: x")
+
+	var errs ListError
+	errs.Add("no main")
+	errs.AddError(list)
+	errs.AddError(Error{Severity: SeverityWarning, Msg: "deprecated"})
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "1")
+	if !NewPrinter(new(bytes.Buffer)).Color {
+		t.Error("expected colors with CLICOLOR_FORCE")
+	}
+	t.Setenv("CLICOLOR_FORCE", "")
+
+	var buf bytes.Buffer
+	p := NewPrinter(&buf)
+	if p.Color {
+		t.Error("expected no colors writing to a buffer")
+	}
+	if err := p.Print(errs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "error: no main\n" +
+		"warning: deprecated\n" +
+		filename + ":\n" +
+		"  2:6: error: undefined: x\n" +
+		"      | \tfoo(x)\n" +
+		"      | \t    ^\n" +
+		"2 errors, 1 warning\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merrors
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tredoe/goutil/internal/term"
+)
+
+// ANSI escape codes
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+var severityColors = [...]string{
+	SeverityError:   ansiRed,
+	SeverityWarning: ansiYellow,
+	SeverityInfo:    ansiCyan,
+}
+
+// A Printer prints errors in a way suitable for the users of command-line
+// programs: the errors with position are grouped by file, with an excerpt of
+// the source line, and a summary is printed at the end.
+//
+// It handles the types ListError, MapError, *FieldErrors and the ones of
+// package "go/scanner"; any other error is printed as a single error.
+type Printer struct {
+	Color   bool // use ANSI colors
+	Source  bool // print the source line of the errors with position
+	Summary bool // print a summary like "3 errors, 2 warnings"
+
+	w     io.Writer
+	lines map[string][]string // source lines by file name
+}
+
+// NewPrinter returns a Printer which writes to w, with all options enabled.
+// The colors are used if w is a terminal, unless the environment variable
+// NO_COLOR is set and not empty, or if CLICOLOR_FORCE is set and not "0".
+func NewPrinter(w io.Writer) *Printer {
+	return &Printer{
+		Color:   term.ColorEnabled(w),
+		Source:  true,
+		Summary: true,
+		w:       w,
+	}
+}
+
+// Print prints err.
+func (p *Printer) Print(err error) error {
+	var list ListError
+	list.AddError(err)
+	if len(list) == 0 {
+		return nil
+	}
+	b := bufio.NewWriter(p.w)
+
+	// The errors without position are printed at first.
+	var files []string
+	byFile := make(map[string]ListError)
+
+	for _, v := range list {
+		if v.Pos.Filename == "" {
+			p.printError(b, "", v)
+			continue
+		}
+		if _, found := byFile[v.Pos.Filename]; !found {
+			files = append(files, v.Pos.Filename)
+		}
+		byFile[v.Pos.Filename] = append(byFile[v.Pos.Filename], v)
+	}
+
+	for _, file := range files {
+		fmt.Fprintf(b, "%s:\n", p.style(ansiBold, file))
+		for _, v := range byFile[file] {
+			p.printError(b, "  ", v)
+		}
+	}
+
+	if p.Summary {
+		fmt.Fprintln(b, summary(list))
+	}
+	return b.Flush()
+}
+
+// printError prints the error e, and its source line if it is enabled.
+func (p *Printer) printError(w io.Writer, indent string, e Error) {
+	pos := ""
+	if e.Pos.IsValid() {
+		pos = fmt.Sprintf("%d:%d: ", e.Pos.Line, e.Pos.Column)
+	}
+	msg := e.message()
+	if e.Key != "" {
+		msg = e.Key + ": " + msg
+	}
+	severity := e.Severity.String()
+	if e.Severity >= 0 && int(e.Severity) < len(severityColors) {
+		severity = p.style(severityColors[e.Severity], severity)
+	}
+
+	fmt.Fprintf(w, "%s%s%s: %s\n", indent, pos, severity, msg)
+
+	if !p.Source || !e.Pos.IsValid() {
+		return
+	}
+	line, ok := p.sourceLine(e.Pos.Filename, e.Pos.Line)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "%s    | %s\n", indent, line)
+
+	if e.Pos.Column > 0 && e.Pos.Column <= len(line)+1 {
+		// Keep the tabs so the caret is aligned with the column.
+		pad := []byte(line[:e.Pos.Column-1])
+		for i, c := range pad {
+			if c != '\t' {
+				pad[i] = ' '
+			}
+		}
+		fmt.Fprintf(w, "%s    | %s%s\n", indent, pad, p.style(ansiGreen, "^"))
+	}
+}
+
+// sourceLine returns the line number n of the given file.
+// The files are read once.
+func (p *Printer) sourceLine(filename string, n int) (string, bool) {
+	if p.lines == nil {
+		p.lines = make(map[string][]string)
+	}
+	lines, found := p.lines[filename]
+	if !found {
+		if data, err := os.ReadFile(filename); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		p.lines[filename] = lines
+	}
+
+	if n < 1 || n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+// style returns s with the ANSI code, if the colors are enabled.
+func (p *Printer) style(code, s string) string {
+	if !p.Color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// summary returns the number of errors by severity, like "3 errors, 2 warnings".
+func summary(list ListError) string {
+	counts := make([]int, len(severityNames))
+	for _, v := range list {
+		if v.Severity >= 0 && int(v.Severity) < len(counts) {
+			counts[v.Severity]++
+		} else {
+			counts[SeverityError]++
+		}
+	}
+
+	parts := make([]string, 0, len(counts))
+	for sev, n := range counts {
+		if n == 0 {
+			continue
+		}
+		name := severityNames[sev]
+		if n != 1 && Severity(sev) != SeverityInfo {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, name))
+	}
+	return strings.Join(parts, ", ")
+}
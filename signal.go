@@ -0,0 +1,60 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package goutil
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// HandleSignals handles the given signals, or the signals of interruption
+// (CTRL-C) and termination if none is given.
+//
+// At receiving the first signal, the hooks registered by AtExit are run and
+// the program exits with the status 128+n, where n is the signal number.
+// A second signal exits immediately, without waiting for the hooks.
+//
+// The returned function stops handling the signals; it can be called more
+// than once.
+func HandleSignals(sigs ...os.Signal) (stop func()) {
+	return DefaultExiter.HandleSignals(sigs...)
+}
+
+// HandleSignals handles the given signals, like the package-level function.
+func (e *Exiter) HandleSignals(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultSignals
+	}
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, sigs...)
+
+	done := make(chan struct{})
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-c:
+		case <-done:
+			return
+		}
+
+		go func() {
+			e.exit(signalStatus(<-c))
+		}()
+
+		e.runHooks()
+		e.exit(signalStatus(sig))
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
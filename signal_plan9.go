@@ -0,0 +1,17 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package goutil
+
+import "os"
+
+var defaultSignals = []os.Signal{os.Interrupt}
+
+// signalStatus returns the exit status for the signal sig.
+// The notes of Plan 9 have no number.
+func signalStatus(sig os.Signal) int {
+	return ExitFailure
+}
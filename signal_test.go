@@ -0,0 +1,107 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package goutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// envSignal sets the mode of the test program run by TestHandleSignals.
+const envSignal = "GOUTIL_TEST_SIGNAL"
+
+func TestHandleSignals(t *testing.T) {
+	if mode := os.Getenv(envSignal); mode != "" {
+		signalProgram(mode)
+		return
+	}
+
+	tests := []struct {
+		mode   string
+		sigs   []os.Signal // sent after every line read
+		output []string
+		code   int // -1 if the program is killed by the signal
+	}{
+		// The hooks are run at the first signal.
+		{"hooks", []os.Signal{os.Interrupt}, []string{"ready", "hook"}, 130},
+		{"hooks", []os.Signal{syscall.SIGTERM}, []string{"ready", "hook"}, 143},
+		// A second signal exits without waiting for the hooks.
+		{"force", []os.Signal{os.Interrupt, syscall.SIGTERM}, []string{"ready", "hook"}, 143},
+		// The signals are not handled after stop.
+		{"stop", []os.Signal{os.Interrupt}, []string{"ready"}, -1},
+	}
+
+	for _, tt := range tests {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHandleSignals$")
+		cmd.Env = append(os.Environ(), envSignal+"="+tt.mode)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		timer := time.AfterFunc(10*time.Second, func() { cmd.Process.Kill() })
+
+		var output []string
+		s := bufio.NewScanner(stdout)
+		for i := 0; s.Scan(); i++ {
+			output = append(output, s.Text())
+			if i < len(tt.sigs) {
+				if err = cmd.Process.Signal(tt.sigs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		err = cmd.Wait()
+		timer.Stop()
+
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("%s %v: expected an exit error, got %v", tt.mode, tt.sigs, err)
+			continue
+		}
+		if code := exitErr.ExitCode(); code != tt.code {
+			t.Errorf("%s %v: expected exit status %d, got %d", tt.mode, tt.sigs, tt.code, code)
+		}
+		if fmt.Sprint(output) != fmt.Sprint(tt.output) {
+			t.Errorf("%s %v: expected output %q, got %q", tt.mode, tt.sigs, tt.output, output)
+		}
+	}
+}
+
+// signalProgram is run by TestHandleSignals into a new process.
+// It prints "ready" when the signals are handled, and "hook" when the hook
+// registered at exit is run.
+func signalProgram(mode string) {
+	e := new(Exiter)
+	e.AtExit(func() {
+		fmt.Println("hook")
+		if mode == "force" {
+			time.Sleep(5 * time.Second)
+		}
+	})
+
+	stop := e.HandleSignals()
+	if mode == "stop" {
+		stop()
+		stop()
+	}
+	fmt.Println("ready")
+
+	time.Sleep(5 * time.Second)
+	os.Exit(3)
+}
@@ -0,0 +1,22 @@
+// Copyright 2014 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package goutil
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultSignals = []os.Signal{os.Interrupt}
+
+// signalStatus returns the exit status for the signal sig.
+func signalStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return ExitFailure
+}